@@ -9,7 +9,7 @@ import (
 // OpenTelemetry tracing.
 func NewApplicationScanningResolverWithTracing(externalDNS []string) Resolver {
 	checker := applicationscanning.Checker{}
-	checkerWithTracing := opentelemetry.NewCheckerWithTracing(checker, "checker")
+	checkerWithTracing := opentelemetry.NewCheckerWithTracing(checker, "checker", opentelemetry.CheckerSpanDecorator)
 	resolver := applicationscanning.NewResolverWithCheckers(externalDNS, checkerWithTracing)
-	return opentelemetry.NewResolverWithTracing(resolver, "resolver")
+	return opentelemetry.NewResolverWithTracing(resolver, "resolver", opentelemetry.ResolverSpanDecorator)
 }
@@ -0,0 +1,282 @@
+package applicationscanning
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+)
+
+// authenticatedTransport returns the transport requests to the URL provided, authenticated with the credentials
+// provided, are sent with. Client certificates require a transport of their own, so that connections authenticated
+// with them are not shared with other requests.
+func (c Checker) authenticatedTransport(auth endpointresolver.Auth, requestURL *url.URL) http.RoundTripper {
+	transport := c.httpTransport()
+	if len(auth.ClientCertificates) > 0 {
+		transport = c.certTransports().transport(transport, auth.ClientCertificates)
+	}
+	if len(auth.Username) == 0 {
+		return transport
+	}
+	return challengeTransport{
+		base:               transport,
+		scheme:             requestURL.Scheme,
+		host:               requestURL.Host,
+		username:           auth.Username,
+		password:           auth.Password,
+		allowBasicOverHTTP: auth.AllowBasicOverHTTP,
+	}
+}
+
+// clientCertTransports holds the transports presenting client certificates, keyed by the certificates they present,
+// so that connections authenticated with the same certificates are pooled across requests.
+type clientCertTransports struct {
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+// defaultClientCertTransports holds the transports cloned from defaultTransport.
+var defaultClientCertTransports = newClientCertTransports()
+
+func newClientCertTransports() *clientCertTransports {
+	return &clientCertTransports{transports: make(map[string]*http.Transport)}
+}
+
+// transport returns the transport presenting the certificates provided, cloned from the base transport provided the
+// first time they are presented, with a TLS session cache of its own.
+func (t *clientCertTransports) transport(base *http.Transport, certificates []tls.Certificate) *http.Transport {
+	key := certificatesKey(certificates)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if transport, ok := t.transports[key]; ok {
+		return transport
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig.Certificates = certificates
+	transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	t.transports[key] = transport
+	return transport
+}
+
+// closeIdleConnections closes the idle connections of every transport held.
+func (t *clientCertTransports) closeIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, transport := range t.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// certificatesKey returns the hash of the certificate chains provided.
+func certificatesKey(certificates []tls.Certificate) string {
+	h := sha256.New()
+	for _, certificate := range certificates {
+		_ = binary.Write(h, binary.BigEndian, uint32(len(certificate.Certificate)))
+		for _, der := range certificate.Certificate {
+			_ = binary.Write(h, binary.BigEndian, uint32(len(der)))
+			h.Write(der)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c Checker) certTransports() *clientCertTransports {
+	if c.clientCertTransports == nil {
+		return defaultClientCertTransports
+	}
+	return c.clientCertTransports
+}
+
+// cookieJar returns a cookie jar holding the cookies provided for the URL provided, or nil when there are none.
+func cookieJar(u *url.URL, cookies []*http.Cookie) http.CookieJar {
+	if len(cookies) == 0 {
+		return nil
+	}
+	// creating a cookie jar without options never fails
+	jar, _ := cookiejar.New(nil)
+	jar.SetCookies(u, cookies)
+	return jar
+}
+
+// challengeTransport answers HTTP Basic and Digest challenges with the credentials provided, by sending a request again
+// once when its response is a challenge which can be answered. Only challenges sent by the scheme and host the
+// credentials are meant for are answered, so that they never leak to the ones redirected to.
+type challengeTransport struct {
+	base               http.RoundTripper
+	scheme             string
+	host               string
+	username           string
+	password           string
+	allowBasicOverHTTP bool
+}
+
+// RoundTrip implements http.RoundTripper
+func (t challengeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(r)
+	if err != nil || response.StatusCode != http.StatusUnauthorized || len(r.Header.Get("Authorization")) > 0 {
+		return response, err
+	}
+	if r.URL.Scheme != t.scheme || r.URL.Host != t.host {
+		return response, nil
+	}
+	// requests whose body cannot be read again cannot be sent again
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return response, nil
+	}
+	authorization, ok := t.answer(r, response.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return response, nil
+	}
+
+	retry := r.Clone(r.Context())
+	if r.GetBody != nil {
+		if retry.Body, err = r.GetBody(); err != nil {
+			return response, nil
+		}
+	}
+	retry.Header.Set("Authorization", authorization)
+	drainAndClose(response.Body)
+	return t.base.RoundTrip(retry)
+}
+
+// answer returns the Authorization header answering the challenges provided, preferring Digest over Basic, and whether
+// any of them could be answered.
+func (t challengeTransport) answer(r *http.Request, challenges []string) (string, bool) {
+	var basic bool
+	for _, challenge := range challenges {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+		switch {
+		case strings.EqualFold(scheme, "Digest"):
+			if authorization, ok := t.digest(r, parseAuthParams(params)); ok {
+				return authorization, true
+			}
+		case strings.EqualFold(scheme, "Basic"):
+			basic = true
+		}
+	}
+	// Basic credentials are sent in clear over plain HTTP
+	if !basic || (r.URL.Scheme != schemeHTTPS && !t.allowBasicOverHTTP) {
+		return "", false
+	}
+	basicRequest := http.Request{Header: make(http.Header)}
+	basicRequest.SetBasicAuth(t.username, t.password)
+	return basicRequest.Header.Get("Authorization"), true
+}
+
+// digest returns the Authorization header answering the Digest challenge whose parameters are provided, as defined by
+// RFC 7616, and whether its algorithm is supported.
+func (t challengeTransport) digest(r *http.Request, params map[string]string) (string, bool) {
+	algorithm := params["algorithm"]
+	if len(algorithm) == 0 {
+		algorithm = "MD5"
+	}
+	session := strings.HasSuffix(strings.ToUpper(algorithm), "-SESS")
+
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", false
+	}
+	digest := func(values ...string) string {
+		h := newHash()
+		h.Write([]byte(strings.Join(values, ":")))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	realm, nonce := params["realm"], params["nonce"]
+	uri := r.URL.RequestURI()
+	cnonce := newClientNonce()
+	const nc = "00000001"
+
+	ha1 := digest(t.username, realm, t.password)
+	if session {
+		ha1 = digest(ha1, nonce, cnonce)
+	}
+	ha2 := digest(r.Method, uri)
+
+	var qop string
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%q", t.username),
+		fmt.Sprintf("realm=%q", realm),
+		fmt.Sprintf("nonce=%q", nonce),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + algorithm,
+	}
+	if len(qop) > 0 {
+		fields = append(fields,
+			fmt.Sprintf("response=%q", digest(ha1, nonce, nc, cnonce, qop, ha2)),
+			"qop="+qop,
+			"nc="+nc,
+			fmt.Sprintf("cnonce=%q", cnonce),
+		)
+	} else {
+		fields = append(fields, fmt.Sprintf("response=%q", digest(ha1, nonce, ha2)))
+	}
+	if opaque, ok := params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf("opaque=%q", opaque))
+	}
+	return "Digest " + strings.Join(fields, ", "), true
+}
+
+// parseAuthParams parses the comma separated parameters of a challenge, whose values may be quoted.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " ")
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			if i < len(rest) {
+				// skip the closing quote
+				i++
+			}
+			value, s = b.String(), rest[i:]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[key] = value
+	}
+	return params
+}
+
+func newClientNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,168 @@
+package applicationscanning
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRequest_AnswersBasicChallenge(t *testing.T) {
+	serverURL := newLocalServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	// Basic credentials are only sent over plain HTTP when allowed
+	auth := endpointresolver.Auth{Username: "user", Password: "secret"}
+	_, result, err := Checker{}.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent, auth: auth})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, result.Response.StatusCode)
+
+	auth.AllowBasicOverHTTP = true
+	_, result, err = Checker{}.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent, auth: auth})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, result.Response.StatusCode)
+}
+
+func TestSendRequest_AnswersDigestChallenge(t *testing.T) {
+	const realm, nonce = "test", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	md5Hex := func(s string) string {
+		hash := md5.Sum([]byte(s))
+		return hex.EncodeToString(hash[:])
+	}
+	serverURL := newLocalServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := parseAuthParams(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+		ha1 := md5Hex("user:" + realm + ":secret")
+		ha2 := md5Hex(r.Method + ":" + params["uri"])
+		expected := md5Hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+		if params["response"] != expected || params["opaque"] != "opaque, value" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth,auth-int", nonce="`+nonce+`", opaque="opaque, value"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	auth := endpointresolver.Auth{Username: "user", Password: "secret"}
+	_, result, err := Checker{}.sendRequest(context.TODO(), serverURL+"/app?a=1", request{userAgent: mozillaUserAgent, auth: auth})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, result.Response.StatusCode)
+}
+
+func TestSendRequest_AnswersChallengesOnlyFromHostRequested(t *testing.T) {
+	var otherHostAuthorizations []string
+	otherHost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorization := r.Header.Get("Authorization"); len(authorization) > 0 {
+			otherHostAuthorizations = append(otherHostAuthorizations, authorization)
+			return
+		}
+		w.Header().Add("WWW-Authenticate", `Digest realm="other", qop="auth", nonce="abc"`)
+		w.Header().Add("WWW-Authenticate", `Basic realm="other"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(otherHost.Close)
+	serverURL := newLocalServer(t, http.RedirectHandler(otherHost.URL+"/", http.StatusFound))
+
+	checker := NewChecker(CheckerConf{ScopePolicy: endpointresolver.AnyScopes{
+		endpointresolver.SubdomainScope{},
+		endpointresolver.AllowlistScope{Hosts: []string{"127.0.0.1"}},
+	}})
+	auth := endpointresolver.Auth{Username: "user", Password: "secret", AllowBasicOverHTTP: true}
+	_, result, err := checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent, auth: auth})
+	require.NoError(t, err)
+	require.Equal(t, otherHost.URL+"/", result.URL)
+	require.Equal(t, http.StatusUnauthorized, result.Response.StatusCode)
+	require.Empty(t, otherHostAuthorizations)
+}
+
+func TestSendRequest_SendsBearerTokenAndCookies(t *testing.T) {
+	var authorization, cookie string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "redirected", Value: "yes"})
+		http.Redirect(w, r, "/app", http.StatusFound)
+	})
+	mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
+		authorization, cookie = r.Header.Get("Authorization"), r.Header.Get("Cookie")
+	})
+	serverURL := newLocalServer(t, mux)
+
+	auth := endpointresolver.Auth{BearerToken: "token", Cookies: []*http.Cookie{{Name: "session", Value: "secret"}}}
+	_, _, err := Checker{}.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent, auth: auth})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token", authorization)
+	require.Equal(t, "session=secret; redirected=yes", cookie)
+}
+
+func TestSendRequest_PresentsClientCertificates(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	checker := NewChecker(CheckerConf{})
+	_, _, err := checker.sendRequest(context.TODO(), server.URL+"/", request{userAgent: mozillaUserAgent})
+	require.Error(t, err)
+
+	auth := endpointresolver.Auth{ClientCertificates: []tls.Certificate{newClientCertificate(t)}}
+	_, result, err := checker.sendRequest(context.TODO(), server.URL+"/", request{userAgent: mozillaUserAgent, auth: auth})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, result.Response.StatusCode)
+}
+
+func TestSendRequest_ReusesConnectionsWithClientCertificates(t *testing.T) {
+	var conns int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	checker := NewChecker(CheckerConf{})
+	defer checker.CloseIdleConnections()
+	certificate := newClientCertificate(t)
+	for i := 0; i < 5; i++ {
+		// the certificates are provided anew with each resolving config
+		auth := endpointresolver.Auth{ClientCertificates: []tls.Certificate{{Certificate: certificate.Certificate, PrivateKey: certificate.PrivateKey}}}
+		_, _, err := checker.sendRequest(context.TODO(), server.URL+"/", request{userAgent: mozillaUserAgent, auth: auth})
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(1), atomic.LoadInt64(&conns))
+}
+
+// newClientCertificate generates a self-signed client certificate.
+func newClientCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
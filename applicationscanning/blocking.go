@@ -0,0 +1,182 @@
+package applicationscanning
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+)
+
+// defaultReferenceUserAgents are the reference user agents used when none are configured.
+var defaultReferenceUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0",
+}
+
+// blockStatusCodes are the HTTP status codes block and challenge pages are usually served with.
+var blockStatusCodes = map[int]bool{
+	http.StatusForbidden:          true,
+	http.StatusNotAcceptable:      true,
+	http.StatusTooManyRequests:    true,
+	http.StatusServiceUnavailable: true,
+}
+
+// challengeMarkers are lowercase strings found in bot challenge and block pages.
+var challengeMarkers = []string{
+	"captcha",
+	"challenge-platform",
+	"cf-chl-",
+	"_incapsula_resource",
+	"access denied",
+	"request blocked",
+	"are you a robot",
+	"verify you are human",
+	"unusual traffic",
+}
+
+// findChallengeMarkers returns the challenge markers found in the body provided.
+func findChallengeMarkers(body []byte) []string {
+	body = bytes.ToLower(body)
+
+	var markers []string
+	for _, marker := range challengeMarkers {
+		if bytes.Contains(body, []byte(marker)) {
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
+
+// looksBlocked reports whether a response looks like a block or challenge page.
+func looksBlocked(response endpointresolver.ResponseMetadata) bool {
+	return blockStatusCodes[response.StatusCode] || len(response.ChallengeMarkers) > 0
+}
+
+// detectBlocks requests the URLs found which look blocked again with a reference user agent, and returns the evidence
+// of those whose response materially differs, meaning that they are blocked due to the user agent, by URL.
+func (c Checker) detectBlocks(ctx context.Context, urls map[url.URL]endpointresolver.URLResult, req request) (map[string]endpointresolver.BlockEvidence, error) {
+	blocked := make(map[string]endpointresolver.BlockEvidence)
+	for _, result := range urls {
+		if !looksBlocked(result.Response) {
+			continue
+		}
+
+		// when every reference user agent is blocked too, the block is not due to the user agent
+		referenceUserAgent, reference, err := c.sendReferenceRequest(ctx, result.RequestedURL, req, func(reference endpointresolver.URLResult) bool {
+			return !looksBlocked(reference.Response)
+		})
+		switch {
+		case err == context.Canceled:
+			return nil, err
+		case err != nil:
+			continue
+		}
+
+		if result.Response.StatusCode == reference.Response.StatusCode && result.Response.BodyHash == reference.Response.BodyHash {
+			continue
+		}
+		blocked[result.URL] = newBlockEvidence(result, reference, referenceUserAgent)
+	}
+	return blocked, nil
+}
+
+// errReferenceBlocked is returned when every reference user agent was blocked.
+var errReferenceBlocked = errors.New("every reference user agent was blocked")
+
+// sendReferenceRequest sends the request provided with each reference user agent in order, until one succeeds and its
+// result is accepted, if an accept function is provided. Returns the user agent which succeeded along with its result.
+func (c Checker) sendReferenceRequest(ctx context.Context, requestURL string, req request, accept func(endpointresolver.URLResult) bool) (string, endpointresolver.URLResult, error) {
+	err := errReferenceBlocked
+	for _, userAgent := range c.referenceUserAgents() {
+		var result endpointresolver.URLResult
+		_, result, err = c.sendRequestWithRetry(ctx, requestURL, req.withUserAgent(userAgent))
+		c.log().DebugContext(ctx, "reference request sent", "stage", endpointresolver.StageHTTP, "url", requestURL,
+			"reference_user_agent", userAgent, "status_code", result.Response.StatusCode, "error", err)
+		switch {
+		case err == context.Canceled:
+			return "", endpointresolver.URLResult{}, err
+		case err != nil:
+			continue
+		case accept != nil && !accept(result):
+			err = errReferenceBlocked
+			continue
+		}
+		return userAgent, result, nil
+	}
+	return "", endpointresolver.URLResult{}, err
+}
+
+func (c Checker) referenceUserAgents() []string {
+	if len(c.conf.ReferenceUserAgents) == 0 {
+		return defaultReferenceUserAgents
+	}
+	return c.conf.ReferenceUserAgents
+}
+
+// newBlockEvidence returns the evidence of a request blocked due to its user agent, from its result, which has no
+// response when none was received, and the result of the same request with the reference user agent provided.
+func newBlockEvidence(result, reference endpointresolver.URLResult, referenceUserAgent string) endpointresolver.BlockEvidence {
+	providers := make(map[string]struct{})
+	for _, response := range []endpointresolver.ResponseMetadata{result.Response, reference.Response} {
+		for _, provider := range response.Providers {
+			providers[provider] = struct{}{}
+		}
+	}
+
+	evidence := endpointresolver.BlockEvidence{
+		URL:                 result.RequestedURL,
+		StatusCode:          result.Response.StatusCode,
+		BodyHash:            result.Response.BodyHash,
+		ChallengeMarkers:    result.Response.ChallengeMarkers,
+		ReferenceUserAgent:  referenceUserAgent,
+		ReferenceStatusCode: reference.Response.StatusCode,
+		ReferenceBodyHash:   reference.Response.BodyHash,
+	}
+	for provider := range providers {
+		evidence.Providers = append(evidence.Providers, provider)
+	}
+	sort.Strings(evidence.Providers)
+	return evidence
+}
+
+// blockedError returns the error describing a request blocked due to its user agent, naming the WAF or CDN providers
+// detected, if any.
+func blockedError(hostname string, evidence endpointresolver.BlockEvidence, cause error) error {
+	err := &endpointresolver.ResolveError{
+		Stage:    endpointresolver.StageHTTP,
+		Err:      endpointresolver.ErrBlockedByUserAgent,
+		Hostname: hostname,
+		URL:      evidence.URL,
+		Cause:    cause,
+		Evidence: &evidence,
+	}
+	if u, parseErr := url.Parse(evidence.URL); parseErr == nil {
+		err.Port = endpointresolver.URLPort(u)
+	}
+	if len(evidence.Providers) > 0 {
+		err.Err = endpointresolver.ErrBlockedByWAF
+		err.Provider = strings.Join(evidence.Providers, ", ")
+	}
+	return err
+}
+
+// blockWarnings returns a warning for each URL blocked due to the user agent.
+func blockWarnings(blocked map[string]endpointresolver.BlockEvidence) []endpointresolver.Warning {
+	var warnings []endpointresolver.Warning
+	for u, evidence := range blocked {
+		warnings = append(warnings, endpointresolver.Warning{
+			Code: endpointresolver.WarningBlockedByUserAgent,
+			Message: fmt.Sprintf("responded %d to the user agent but %d to the reference user agent %q",
+				evidence.StatusCode, evidence.ReferenceStatusCode, evidence.ReferenceUserAgent),
+			URL: u,
+		})
+	}
+	return warnings
+}
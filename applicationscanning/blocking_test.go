@@ -0,0 +1,113 @@
+package applicationscanning
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+// newBlockingServer starts a test server responding with the status and body provided to any user agent but the
+// reference one, and returns its port.
+func newBlockingServer(t *testing.T, blockedStatus int, blockedBody string) int {
+	return newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != defaultReferenceUserAgents[0] {
+			w.WriteHeader(blockedStatus)
+			_, _ = w.Write([]byte(blockedBody))
+			return
+		}
+		_, _ = w.Write([]byte("<html><title>Welcome</title></html>"))
+	}))
+}
+
+func TestHTTP_DetectsBlockPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		markers []string
+	}{
+		{"forbidden", http.StatusForbidden, "Forbidden", nil},
+		{"challenge page", http.StatusOK, "<html>Please complete the CAPTCHA</html>", []string{"captcha"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := newBlockingServer(t, tt.status, tt.body)
+
+			_, _, err := Checker{}.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: "scanner"}, "localhost", []int{port})
+			require.ErrorIs(t, err, endpointresolver.ErrBlockedByUserAgent)
+
+			var resolveErr *endpointresolver.ResolveError
+			require.ErrorAs(t, err, &resolveErr)
+			require.NotNil(t, resolveErr.Evidence)
+			require.Equal(t, tt.status, resolveErr.Evidence.StatusCode)
+			require.Equal(t, http.StatusOK, resolveErr.Evidence.ReferenceStatusCode)
+			require.Equal(t, tt.markers, resolveErr.Evidence.ChallengeMarkers)
+			require.Equal(t, defaultReferenceUserAgents[0], resolveErr.Evidence.ReferenceUserAgent)
+		})
+	}
+}
+
+func TestHTTP_IgnoresBlocksNotDueToUserAgent(t *testing.T) {
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	urls, warnings, err := Checker{}.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: "scanner"}, "localhost", []int{port})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(urls))
+	require.Empty(t, warnings)
+}
+
+func TestHTTP_WarnsOnPartialBlocks(t *testing.T) {
+	blockingPort := newBlockingServer(t, http.StatusForbidden, "Access denied")
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	urls, warnings, err := Checker{}.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: "scanner"}, "localhost", []int{blockingPort, port})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(urls))
+	require.Equal(t, []endpointresolver.Warning{{
+		Code:    endpointresolver.WarningBlockedByUserAgent,
+		Message: "responded 403 to the user agent but 200 to the reference user agent \"" + defaultReferenceUserAgents[0] + "\"",
+		URL:     "http://localhost:" + strconv.Itoa(blockingPort) + "/",
+	}}, warnings)
+}
+
+func TestHTTP_TriesReferenceUserAgentsInOrder(t *testing.T) {
+	referenceUserAgents := []string{"blocked reference", "allowed reference"}
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != referenceUserAgents[1] {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+
+	checker := NewChecker(CheckerConf{ReferenceUserAgents: referenceUserAgents})
+	_, _, err := checker.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: "scanner"}, "localhost", []int{port})
+	require.ErrorIs(t, err, endpointresolver.ErrBlockedByUserAgent)
+
+	var resolveErr *endpointresolver.ResolveError
+	require.ErrorAs(t, err, &resolveErr)
+	require.Equal(t, referenceUserAgents[1], resolveErr.Evidence.ReferenceUserAgent)
+}
+
+func TestHTTP_DetectsBlocksOnEveryPath(t *testing.T) {
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first path fails for every user agent, and the second one for any but the reference one
+		if r.URL.Path == "/" || r.UserAgent() != defaultReferenceUserAgents[0] {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		}
+	}))
+
+	conf := endpointresolver.ResolveConf{UserAgent: "scanner", Paths: []string{"/", "/app/"}}
+	_, _, err := Checker{}.HTTP(context.TODO(), conf, "localhost", []int{port})
+	require.ErrorIs(t, err, endpointresolver.ErrBlockedByUserAgent)
+
+	var resolveErr *endpointresolver.ResolveError
+	require.ErrorAs(t, err, &resolveErr)
+	require.Equal(t, "http://localhost:"+strconv.Itoa(port)+"/app/", resolveErr.Evidence.URL)
+	require.NotNil(t, resolveErr.Cause)
+}
@@ -2,23 +2,156 @@ package applicationscanning
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/cenkalti/backoff"
 	endpointresolver "github.com/detectify/endpoint-resolver"
 	"github.com/detectify/n5/ip"
 	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"net"
+	"net/http"
 	"net/url"
+	"sort"
 	"time"
 )
 
+// Checker implements the Checker interface and executes the endpoint resolution checks used by Application Scanning.
+// A Checker not created through NewChecker, such as the zero value, uses the default configuration, and shares its
+// dialer and connection pool with every other such Checker.
 type Checker struct {
+	conf                 CheckerConf
+	dialer               *dialer
+	transport            *http.Transport
+	clientCertTransports *clientCertTransports
+	limiter              *rateLimiter
+	tracer               trace.Tracer
+	counters             *counters
 }
 
-// ExternalDNS initiates the DNS resolution process by using an external DNS provider as the resolver.
+// CheckerConf holds the configuration to be used by the Checker
+type CheckerConf struct {
+	// ScopePolicy decides whether redirects are followed and whether the URLs found are in scope. Defaults to the
+	// endpoint hostname and its subdomains.
+	ScopePolicy endpointresolver.ScopePolicy
+
+	// MaxIdleConns limits the number of idle HTTP connections kept open across all hosts. Defaults to 100.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost limits the number of idle HTTP connections kept open per host. Defaults to 2.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is how long an idle HTTP connection is kept open before being closed. Defaults to 90 seconds.
+	IdleConnTimeout time.Duration
+
+	// TLSSessionCacheSize is the number of TLS sessions cached for resumption. Defaults to 64.
+	TLSSessionCacheSize int
+
+	// DialTimeout limits how long establishing an HTTP connection may take. Defaults to 10 seconds.
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout limits how long a TLS handshake may take. Defaults to 10 seconds.
+	TLSHandshakeTimeout time.Duration
+
+	// ProbeProtocols enables probing which protocols each URL found supports, beyond the one its response was received
+	// over. HTTP/1.1 and HTTP/2 support is probed through ALPN, and HTTP/3 through a QUIC handshake when advertised.
+	ProbeProtocols bool
+
+	// RequiredProtocol, when set, enables protocol probing and discards URLs which do not support the protocol.
+	RequiredProtocol endpointresolver.Protocol
+
+	// QUICProber confirms HTTP/3 support advertised through the Alt-Svc header. Defaults to performing a QUIC handshake
+	// over UDP from the source addresses configured.
+	QUICProber QUICProber
+
+	// Proxy selects the proxy used for port checks, HTTP requests and external DNS queries. When set, external DNS
+	// queries are sent over TCP so that they can be proxied. Native DNS queries and QUIC probes are never proxied.
+	Proxy ProxyFunc
+
+	// RateLimits limits the rate of DNS queries, TCP dials and HTTP requests, across all resolutions using the Checker.
+	RateLimits RateLimits
+
+	// LocalAddrs are the source addresses DNS queries, port checks and HTTP requests are sent from, used in a
+	// round-robin fashion. When using a proxy, these are the addresses the proxy is connected from.
+	LocalAddrs []net.IP
+
+	// Interface is the name of the network interface whose IPv4 addresses are used as source addresses when
+	// LocalAddrs is empty.
+	Interface string
+
+	// RetryPolicies configures how each check stage is retried when it fails.
+	RetryPolicies RetryPolicies
+
+	// SlowResponse configures which timing of a response, and above which threshold, raises a slow response warning.
+	SlowResponse SlowResponse
+
+	// ResponseHeaders are the names of the response headers reported for each URL found. Defaults to Server,
+	// X-Powered-By and Via.
+	ResponseHeaders []string
+
+	// BodyReadLimit is the number of bytes read from the body of each response to find its title and hash it. Defaults
+	// to 64 KiB, and a negative value skips reading bodies.
+	BodyReadLimit int64
+
+	// ReferenceUserAgents are the user agents requests are sent with again, in order until one succeeds, to tell whether
+	// the user agent provided is blocked. Defaults to recent versions of Chrome, Safari and Firefox.
+	ReferenceUserAgents []string
+
+	// Deduplicate enables returning a single canonical URL among equivalent ones, preferring HTTPS and default ports.
+	// URLs are equivalent when they are reached from the same requested URLs, or are on the same host and respond with
+	// the same status code and body. The others are listed as aliases of the canonical URL.
+	Deduplicate bool
+
+	// TracerProvider provides the tracer of the child spans started for each DNS query, TCP dial and HTTP request, including
+	// each redirect followed. Without it, no span is started.
+	TracerProvider trace.TracerProvider
+
+	// MeterProvider provides the meter of the counters of DNS queries sent and TCP dials made. Without it, nothing is
+	// counted.
+	MeterProvider metric.MeterProvider
+
+	// Logger logs the inputs, attempts and decisions of each check at debug level. Without it, nothing is logged.
+	Logger Logger
+}
+
+// NewChecker generates and returns a Checker instance using the configuration provided. The HTTP connections opened
+// by the Checker are pooled and shared by all copies of it, so a Checker is meant to be created once and reused.
+func NewChecker(conf CheckerConf) Checker {
+	limiter := newRateLimiter(conf.RateLimits)
+	dialer := newDialer(conf, limiter)
+	return Checker{
+		conf:                 conf,
+		dialer:               dialer,
+		transport:            newTransport(conf, dialer),
+		clientCertTransports: newClientCertTransports(),
+		limiter:              limiter,
+		tracer:               newTracer(conf.TracerProvider),
+		counters:             newCounters(conf.MeterProvider),
+	}
+}
+
+// CloseIdleConnections closes any HTTP connections kept open for reuse which are currently idle.
+func (c Checker) CloseIdleConnections() {
+	c.httpTransport().CloseIdleConnections()
+	c.certTransports().closeIdleConnections()
+}
+
+// ExternalDNS initiates the DNS resolution process by using an external DNS provider as the resolver. External DNS
+// resolvers can be provided as "host:port" or "tcp://host:port" addresses, or as DNS-over-HTTPS URLs.
 func (c Checker) ExternalDNS(ctx context.Context, hostname string, externalDNS []string) error {
+	netDialer, err := c.connDialer().boundDialer("udp")
+	if err != nil {
+		return &endpointresolver.ResolveError{
+			Stage:    endpointresolver.StageExternalDNS,
+			Err:      endpointresolver.ErrThirdPartyDNSResolutionFailure,
+			Hostname: hostname,
+			Cause:    err,
+		}
+	}
 	client := dns.Client{}
 	client.Timeout = dnsTimeout
+	client.Dialer = netDialer
 
 	req := new(dns.Msg)
 	req.Id = dns.Id()
@@ -26,40 +159,90 @@ func (c Checker) ExternalDNS(ctx context.Context, hostname string, externalDNS [
 	req.Question = make([]dns.Question, 1)
 	req.Question[0] = dns.Question{Name: dns.Fqdn(hostname), Qtype: dns.TypeA, Qclass: dns.ClassINET}
 
-	b := backoff.NewExponentialBackOff()
-	b.MaxElapsedTime = dnsRetriesMaxElapsedTime
-
-	err := backoff.Retry(func() error {
+	var attempt int
+	_, err = c.retry(ctx, endpointresolver.StageExternalDNS, func() error {
+		attempt++
+		// the cause of the failure of the last resolver is the one reported
+		var cause error
 		for _, r := range externalDNS {
-			res, _, err := client.ExchangeContext(ctx, req, r)
+			if err := c.limiter.wait(ctx, hostname); err != nil {
+				return err
+			}
+			spanCtx, span := c.spanTracer().Start(ctx, "dns.exchange", trace.WithAttributes(
+				attribute.String("net.peer.name", hostname),
+				attribute.String("dns.resolver", r),
+				attribute.Int("attempt", attempt),
+			))
+			res, err := c.exchange(spanCtx, &client, req, r)
+			if res != nil {
+				span.SetAttributes(attribute.String("dns.rcode", dns.RcodeToString[res.Rcode]))
+			}
+			endSpan(span, err)
+			c.metricCounters().dnsQueries.Add(ctx, 1, attribute.String("resolver", r), attribute.String("outcome", outcome(err)))
+			c.log().DebugContext(ctx, "DNS query sent", "stage", endpointresolver.StageExternalDNS, "hostname", hostname,
+				"resolver", r, "attempt", attempt, "rcode", rcode(res), "error", err)
 
 			switch {
 			case err == context.Canceled:
 				return err
 			case err != nil:
 				// We got no results, try with next resolver
+				cause = fmt.Errorf("resolver %s: %w", r, err)
 				continue
 			case res == nil || res.Answer == nil:
 				// We got an error or no valid response, try with next resolver
+				cause = &DNSResponseError{Resolver: r}
+				if res != nil {
+					cause = &DNSResponseError{Resolver: r, Rcode: res.Rcode}
+				}
 				continue
 			case res.Rcode == dns.RcodeRefused || res.Rcode == dns.RcodeServerFailure:
 				// We got results, but they were bad, try with next resolver
+				cause = &DNSResponseError{Resolver: r, Rcode: res.Rcode}
 				continue
 			}
 			return nil
 		}
-		return endpointresolver.ErrThirdPartyDNSResolutionFailure
-	}, b)
-	if err != nil {
-		return endpointresolver.ErrThirdPartyDNSResolutionFailure
+		return &endpointresolver.ResolveError{
+			Stage:    endpointresolver.StageExternalDNS,
+			Err:      endpointresolver.ErrThirdPartyDNSResolutionFailure,
+			Hostname: hostname,
+			Cause:    cause,
+		}
+	})
+	switch err.(type) {
+	case nil:
+		return nil
+	case *endpointresolver.ResolveError:
+		return err
+	default:
+		return &endpointresolver.ResolveError{
+			Stage:    endpointresolver.StageExternalDNS,
+			Err:      endpointresolver.ErrThirdPartyDNSResolutionFailure,
+			Hostname: hostname,
+			Cause:    err,
+		}
 	}
-
-	return nil
 }
 
 // NativeDNS initializes the DNS resolution process by using the cluster-internal DNS resolvers.
 func (c Checker) NativeDNS(ctx context.Context, hostname string) (ips []string, err error) {
-	allIps, err := net.DefaultResolver.LookupHost(ctx, hostname)
+	var allIps []string
+	var attempt int
+	_, err = c.retry(ctx, endpointresolver.StageNativeDNS, func() error {
+		attempt++
+		if err := c.limiter.wait(ctx, hostname); err != nil {
+			return err
+		}
+		spanCtx, span := c.spanTracer().Start(ctx, "dns.lookup", trace.WithAttributes(
+			attribute.String("net.peer.name", hostname),
+			attribute.Int("attempt", attempt),
+		))
+		allIps, err = c.connDialer().resolver().LookupHost(spanCtx, hostname)
+		endSpan(span, err)
+		c.metricCounters().dnsQueries.Add(ctx, 1, attribute.String("resolver", "native"), attribute.String("outcome", outcome(err)))
+		return err
+	})
 	switch err {
 	case nil:
 		// since we can process only IPv4 addresses, we filter only for them
@@ -72,20 +255,23 @@ func (c Checker) NativeDNS(ctx context.Context, hostname string) (ips []string,
 	case context.Canceled:
 		return nil, err
 	default:
-		return nil, endpointresolver.ErrNativeDNSResolutionFailure
+		return nil, &endpointresolver.ResolveError{
+			Stage:    endpointresolver.StageNativeDNS,
+			Err:      endpointresolver.ErrNativeDNSResolutionFailure,
+			Hostname: hostname,
+			Cause:    err,
+		}
 	}
 }
 
-// Ports consumes a list of IPs discovered as well as ports and returns back a list of open ports accross them.
-// It does that by looping (max 3 attempts) through the IPs discovered and consequently the ports provided, and executes
-// a TCP-dial on each combination.
+// Ports consumes a list of IPs discovered as well as ports and returns back a list of open ports accross them, in
+// ascending order. It does that by looping through the IPs discovered and consequently the ports provided, and executes
+// a TCP-dial on each combination. Ports not found open are dialed again as configured by the Ports RetryPolicy.
 func (c Checker) Ports(ctx context.Context, ips []string, ports []int) (openPorts []int, err error) {
 	openPortMap := make(map[int]interface{}, 0)
-	dialer := net.Dialer{
-		Timeout: portCheckTimeout,
-	}
+	noOpenPort := &endpointresolver.ResolveError{Stage: endpointresolver.StagePorts, Err: endpointresolver.ErrNoOpenPort}
 
-	for i := 0; i < portRetries; i++ {
+	_, err = c.retry(ctx, endpointresolver.StagePorts, func() error {
 		for _, ipAddress := range ips {
 			for _, port := range ports {
 				if _, ok := openPortMap[port]; ok {
@@ -93,72 +279,170 @@ func (c Checker) Ports(ctx context.Context, ips []string, ports []int) (openPort
 					continue
 				}
 
-				conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", ipAddress, port))
+				// the IP dialed is limited by the dialer
+				if err := c.limiter.wait(ctx, contextHostname(ctx, ipAddress)); err != nil {
+					return err
+				}
+				dialCtx, cancel := context.WithTimeout(ctx, portCheckTimeout)
+				conn, err := c.connDialer().DialContext(dialCtx, "tcp", fmt.Sprintf("%s:%d", ipAddress, port))
+				cancel()
+				c.log().DebugContext(ctx, "port checked", "stage", endpointresolver.StagePorts, "ip", ipAddress, "port", port,
+					"open", err == nil, "error", err)
 				switch err {
 				case nil:
 					_ = conn.Close()
 					openPortMap[port] = struct{}{}
 				case context.Canceled:
-					return nil, err
+					return err
 				default:
+					// the last dial failing is the one reported
+					noOpenPort.IP, noOpenPort.Port, noOpenPort.Cause = ipAddress, port, err
 				}
 			}
 		}
+
+		for _, port := range ports {
+			if _, ok := openPortMap[port]; !ok {
+				// some ports are still closed, so another attempt is worth it
+				return noOpenPort
+			}
+		}
+		return nil
+	})
+	if err != nil && err != error(noOpenPort) {
+		return nil, err
 	}
 
 	if len(openPortMap) == 0 {
-		return nil, endpointresolver.ErrNoOpenPort
+		return nil, noOpenPort
 	}
 
 	for port := range openPortMap {
 		openPorts = append(openPorts, port)
 	}
+	sort.Ints(openPorts)
 
 	return openPorts, nil
 }
 
-// HTTP sends an HTTP request to the open ports found on your hostname and returns back a list of URLs. It might be
-// that no URLs are to be returned - in that case an out-of-scope error might be returned, or a HTTP timeout warning. In
-// the case the user agent provided resulted in the request being blocked, then a relevant error is returned.
-func (c Checker) HTTP(ctx context.Context, userAgent, hostname string, customHeaders map[string]string, openPorts []int) ([]string, error) {
-	urls := make(map[url.URL]time.Duration)
+// HTTP sends an HTTP request, as configured by the resolving config provided, to the open ports found on your hostname
+// and returns back a list of URLs, each with the redirect chain that led to it, along with warnings about URLs reached
+// out of scope or responding slowly. On each port and scheme, the paths configured are requested in order until one
+// responds successfully. URLs are sorted with HTTPS first, then by hostname and port, and warnings by URL. In the case
+// the user agent provided resulted in the request being blocked, then a relevant error is returned.
+func (c Checker) HTTP(ctx context.Context, conf endpointresolver.ResolveConf, hostname string, openPorts []int) ([]endpointresolver.URLResult, []endpointresolver.Warning, error) {
+	req, err := newRequest(conf)
+	if err == nil {
+		err = c.checkBodyAssertions(req)
+	}
+	if err != nil {
+		return nil, nil, &endpointresolver.ResolveError{Stage: endpointresolver.StageHTTP, Err: err, Hostname: hostname}
+	}
+
+	c.log().DebugContext(ctx, "sending requests", "stage", endpointresolver.StageHTTP, "hostname", hostname,
+		"open_ports", openPorts, "method", req.method, "paths", conf.Paths, "user_agent", req.userAgent,
+		"custom_headers", headerNames(conf.CustomHeaders))
+
+	urls := make(map[url.URL]endpointresolver.URLResult)
+	failures := make(map[string]error)
+	noConnection := &endpointresolver.ResolveError{
+		Stage:    endpointresolver.StageHTTP,
+		Err:      endpointresolver.ErrNoHTTPConnection,
+		Hostname: hostname,
+	}
+	// responses failing the assertions expected are reported over failing to connect
+	var unexpected *endpointresolver.ResolveError
 
 	for _, port := range openPorts {
-		for _, candidateURL := range createURLs(hostname, port) {
-			start := time.Now()
-			responseURL, err := sendRequest(ctx, candidateURL, userAgent, customHeaders)
+		for _, candidateURLs := range createURLs(hostname, port, conf.Paths) {
+			candidateURL, responseURL, result, err := c.sendPathsWithRetry(ctx, candidateURLs, req)
 			switch err {
 			case nil:
-				duration := time.Since(start)
-				urls[*responseURL] = duration
+				if existing, ok := urls[*responseURL]; ok && c.conf.Deduplicate {
+					result = mergeRequests(existing, result)
+				}
+				urls[*responseURL] = result
 			case context.Canceled:
-				return nil, err
+				return nil, nil, err
 			default:
+				// the last request failing is the one reported
+				failures[candidateURL] = err
+				noConnection.Port, noConnection.URL, noConnection.Cause = port, candidateURL, err
+				if errors.Is(err, endpointresolver.ErrUnexpectedResponse) && errors.As(err, &unexpected) {
+					unexpected.Hostname, unexpected.Port = hostname, port
+				}
 			}
 		}
 	}
 	if len(urls) > 0 {
-		if !anyWithinScope(urls, hostname, openPorts) {
-			return convertURLs(urls), endpointresolver.WarnRedirectedOutOfScope
+		blocked, err := c.detectBlocks(ctx, urls, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		for u, evidence := range blocked {
+			c.log().DebugContext(ctx, "blocked by user agent", "stage", endpointresolver.StageHTTP, "url", u,
+				"status_code", evidence.StatusCode, "reference_user_agent", evidence.ReferenceUserAgent,
+				"reference_status_code", evidence.ReferenceStatusCode, "providers", evidence.Providers)
 		}
-		if !anyWithinTimeLimit(urls) {
-			return convertURLs(urls), endpointresolver.WarnHTTPTimeout
+		if len(blocked) == len(urls) {
+			// every URL found is blocked, so the first one is reported
+			return nil, nil, blockedError(hostname, blocked[convertURLs(urls)[0].URL], nil)
+		}
+
+		if err := c.checkProtocols(ctx, urls); err != nil {
+			return nil, nil, &endpointresolver.ResolveError{Stage: endpointresolver.StageHTTP, Err: err, Hostname: hostname}
 		}
-		return convertURLs(urls), nil
+		// warnings are only raised for the URLs returned
+		if c.conf.Deduplicate {
+			deduplicate(urls)
+		}
+		warnings := append(scopeWarnings(urls, hostname, openPorts, c.scopePolicy()), timeLimitWarnings(urls, c.conf.SlowResponse)...)
+		warnings = append(warnings, blockWarnings(canonicalBlocks(blocked, urls))...)
+		sortWarnings(warnings)
+		return convertURLs(urls), warnings, nil
 	}
 
 	for _, port := range openPorts {
-		for _, requestURL := range createURLs(hostname, port) {
-			_, err := sendRequest(ctx, requestURL, mozillaUserAgent, customHeaders)
-			switch err {
-			case nil:
-				return nil, endpointresolver.ErrBlockedByUserAgent
-			case context.Canceled:
-				return nil, err
-			default:
+		for _, candidateURLs := range createURLs(hostname, port, conf.Paths) {
+			// the failure reported for the paths is the one of the last path, as returned by sendPathsWithRetry
+			cause := failures[candidateURLs[len(candidateURLs)-1]]
+			for _, requestURL := range candidateURLs {
+				referenceUserAgent, reference, err := c.sendReferenceRequest(ctx, requestURL, req, nil)
+				switch err {
+				case nil:
+					evidence := newBlockEvidence(endpointresolver.URLResult{RequestedURL: requestURL}, reference, referenceUserAgent)
+					return nil, nil, blockedError(hostname, evidence, cause)
+				case context.Canceled:
+					return nil, nil, err
+				default:
+				}
 			}
 		}
 	}
 
-	return nil, endpointresolver.ErrNoHTTPConnection
+	if unexpected != nil {
+		return nil, nil, unexpected
+	}
+	return nil, nil, noConnection
+}
+
+func (c Checker) scopePolicy() endpointresolver.ScopePolicy {
+	if c.conf.ScopePolicy == nil {
+		return endpointresolver.SubdomainScope{}
+	}
+	return c.conf.ScopePolicy
+}
+
+func (c Checker) httpTransport() *http.Transport {
+	if c.transport == nil {
+		return defaultTransport
+	}
+	return c.transport
+}
+
+func (c Checker) connDialer() *dialer {
+	if c.dialer == nil {
+		return defaultDialer
+	}
+	return c.dialer
 }
@@ -0,0 +1,58 @@
+package applicationscanning
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPorts_ReturnsSortedPorts(t *testing.T) {
+	var ports []int
+	for i := 0; i < 5; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = listener.Close() })
+		ports = append([]int{listener.Addr().(*net.TCPAddr).Port}, ports...)
+	}
+
+	for i := 0; i < 10; i++ {
+		openPorts, err := Checker{}.Ports(context.TODO(), []string{"127.0.0.1"}, ports)
+		require.NoError(t, err)
+		require.IsIncreasing(t, openPorts)
+		require.Equal(t, len(ports), len(openPorts))
+	}
+}
+
+func TestExternalDNS_DoesNotRetryNonExistingNames(t *testing.T) {
+	var queries int64
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &dns.Server{Listener: listener, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt64(&queries, 1)
+		res := new(dns.Msg)
+		res.SetRcode(req, dns.RcodeNameError)
+		_ = w.WriteMsg(res)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	err = Checker{}.ExternalDNS(context.TODO(), "nonexisting.example.com", []string{"tcp://" + listener.Addr().String()})
+	require.ErrorIs(t, err, endpointresolver.ErrThirdPartyDNSResolutionFailure)
+	require.Equal(t, int64(1), atomic.LoadInt64(&queries))
+
+	require.False(t, endpointresolver.IsRetryable(&endpointresolver.ResolveError{
+		Stage: endpointresolver.StageExternalDNS,
+		Err:   endpointresolver.ErrThirdPartyDNSResolutionFailure,
+		Cause: &DNSResponseError{Rcode: dns.RcodeNameError},
+	}))
+	require.True(t, endpointresolver.IsRetryable(&endpointresolver.ResolveError{
+		Stage: endpointresolver.StageExternalDNS,
+		Err:   endpointresolver.ErrThirdPartyDNSResolutionFailure,
+		Cause: &DNSResponseError{Rcode: dns.RcodeServerFailure},
+	}))
+}
@@ -0,0 +1,102 @@
+package applicationscanning
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+)
+
+// fingerprint identifies URLs of the same host responding identically.
+type fingerprint struct {
+	hostname   string
+	statusCode int
+	bodyHash   string
+}
+
+// deduplicate keeps a single canonical URL among the ones responding identically on the same host, listing the others
+// as its aliases. URLs whose body was not read are never considered identical to others.
+func deduplicate(urls map[url.URL]endpointresolver.URLResult) {
+	groups := make(map[fingerprint][]url.URL)
+	for u, result := range urls {
+		if len(result.Response.BodyHash) == 0 {
+			continue
+		}
+		key := fingerprint{hostname: u.Hostname(), statusCode: result.Response.StatusCode, bodyHash: result.Response.BodyHash}
+		groups[key] = append(groups[key], u)
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return preferredURL(&group[i], &group[j])
+		})
+
+		canonical := urls[group[0]]
+		for _, u := range group[1:] {
+			alias := urls[u]
+			canonical.Aliases = append(canonical.Aliases, alias.URL)
+			canonical.Aliases = append(canonical.Aliases, alias.Aliases...)
+			delete(urls, u)
+		}
+		sort.Strings(canonical.Aliases)
+		urls[group[0]] = canonical
+	}
+}
+
+// canonicalBlocks returns the evidence of the URLs blocked keyed by the URL returned in their place, either their own
+// or the canonical URL they are an alias of. The evidence of URLs which were discarded is dropped.
+func canonicalBlocks(blocked map[string]endpointresolver.BlockEvidence, urls map[url.URL]endpointresolver.URLResult) map[string]endpointresolver.BlockEvidence {
+	canonicalURLs := make(map[string]string)
+	for _, result := range urls {
+		for _, alias := range result.Aliases {
+			canonicalURLs[alias] = result.URL
+		}
+	}
+	// URLs returned take precedence over the aliases reached through them
+	for _, result := range urls {
+		canonicalURLs[result.URL] = result.URL
+	}
+
+	canonical := make(map[string]endpointresolver.BlockEvidence, len(blocked))
+	for u, evidence := range blocked {
+		canonicalURL, ok := canonicalURLs[u]
+		if !ok {
+			continue
+		}
+		if _, exists := canonical[canonicalURL]; !exists || u == canonicalURL {
+			canonical[canonicalURL] = evidence
+		}
+	}
+	return canonical
+}
+
+// mergeRequests returns a single result for two requests which led to the same URL, keeping the one which requested
+// the URL directly if any, and listing the other requested URL as an alias.
+func mergeRequests(existing, result endpointresolver.URLResult) endpointresolver.URLResult {
+	if result.RequestedURL == result.URL {
+		existing, result = result, existing
+	}
+	existing.Aliases = append(existing.Aliases, result.RequestedURL)
+	existing.Aliases = append(existing.Aliases, result.Aliases...)
+	return existing
+}
+
+// preferredURL reports whether the first URL is preferred over the second as the canonical one, preferring HTTPS,
+// then default ports, then shorter URLs.
+func preferredURL(first, second *url.URL) bool {
+	if (first.Scheme == schemeHTTPS) != (second.Scheme == schemeHTTPS) {
+		return first.Scheme == schemeHTTPS
+	}
+	if (len(first.Port()) == 0) != (len(second.Port()) == 0) {
+		return len(first.Port()) == 0
+	}
+	firstURL, secondURL := first.String(), second.String()
+	if len(firstURL) != len(secondURL) {
+		return len(firstURL) < len(secondURL)
+	}
+	return strings.Compare(firstURL, secondURL) < 0
+}
@@ -0,0 +1,95 @@
+package applicationscanning
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicate_KeepsCanonicalURL(t *testing.T) {
+	page := endpointresolver.ResponseMetadata{StatusCode: http.StatusOK, BodyHash: "abc"}
+	urls := map[url.URL]endpointresolver.URLResult{
+		{Scheme: "http", Host: "example.com:8080", Path: "/"}:  {URL: "http://example.com:8080/", Response: page},
+		{Scheme: "https", Host: "example.com:8443", Path: "/"}: {URL: "https://example.com:8443/", Response: page},
+		{Scheme: "https", Host: "example.com", Path: "/"}: {
+			URL:      "https://example.com/",
+			Response: page,
+			Aliases:  []string{"http://example.com/"},
+		},
+		// different content, host or unread bodies are never merged
+		{Scheme: "https", Host: "example.com:9443", Path: "/"}: {
+			URL:      "https://example.com:9443/",
+			Response: endpointresolver.ResponseMetadata{StatusCode: http.StatusOK, BodyHash: "def"},
+		},
+		{Scheme: "https", Host: "www.example.com", Path: "/"}: {URL: "https://www.example.com/", Response: page},
+		{Scheme: "http", Host: "example.com:8081", Path: "/"}: {URL: "http://example.com:8081/"},
+		{Scheme: "http", Host: "example.com:8082", Path: "/"}: {URL: "http://example.com:8082/"},
+	}
+
+	deduplicate(urls)
+
+	require.Equal(t, 5, len(urls))
+	canonical := urls[url.URL{Scheme: "https", Host: "example.com", Path: "/"}]
+	require.Equal(t, []string{"http://example.com/", "http://example.com:8080/", "https://example.com:8443/"}, canonical.Aliases)
+}
+
+func TestHTTP_DeduplicatesURLs(t *testing.T) {
+	port := newLocalServerPort(t, pageHandler())
+	otherPort := newLocalServerPort(t, pageHandler())
+
+	urls, _, err := NewChecker(CheckerConf{}).HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: mozillaUserAgent}, "localhost", []int{port, otherPort})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(urls))
+
+	urls, _, err = NewChecker(CheckerConf{Deduplicate: true}).HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: mozillaUserAgent}, "localhost", []int{port, otherPort})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(urls))
+	require.Equal(t, 1, len(urls[0].Aliases))
+}
+
+func TestHTTP_RaisesWarningsForDeduplicatedURLs(t *testing.T) {
+	port := newLocalServerPort(t, pageHandler())
+	otherPort := newLocalServerPort(t, pageHandler())
+
+	// every response is slow
+	checker := NewChecker(CheckerConf{Deduplicate: true, SlowResponse: SlowResponse{Threshold: time.Nanosecond}})
+	urls, warnings, err := checker.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: mozillaUserAgent}, "localhost", []int{port, otherPort})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(urls))
+	require.Equal(t, 1, len(warnings))
+	require.Equal(t, urls[0].URL, warnings[0].URL)
+}
+
+func TestCanonicalBlocks_KeysEvidenceByURLReturned(t *testing.T) {
+	urls := map[url.URL]endpointresolver.URLResult{
+		{Scheme: "https", Host: "example.com", Path: "/"}: {
+			URL:     "https://example.com/",
+			Aliases: []string{"http://example.com:8080/"},
+		},
+	}
+	blocked := map[string]endpointresolver.BlockEvidence{
+		"http://example.com:8080/": {StatusCode: http.StatusForbidden},
+		// discarded, e.g. for not supporting the required protocol
+		"http://example.com:8081/": {StatusCode: http.StatusForbidden},
+	}
+
+	require.Equal(t, map[string]endpointresolver.BlockEvidence{
+		"https://example.com/": {StatusCode: http.StatusForbidden},
+	}, canonicalBlocks(blocked, urls))
+}
+
+func TestMergeRequests_KeepsDirectRequest(t *testing.T) {
+	redirected := endpointresolver.URLResult{URL: "https://example.com/", RequestedURL: "http://example.com/"}
+	direct := endpointresolver.URLResult{URL: "https://example.com/", RequestedURL: "https://example.com/"}
+
+	merged := mergeRequests(direct, redirected)
+	require.Equal(t, "https://example.com/", merged.RequestedURL)
+	require.Equal(t, []string{"http://example.com/"}, merged.Aliases)
+
+	require.Equal(t, merged, mergeRequests(redirected, direct))
+}
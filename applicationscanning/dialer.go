@@ -0,0 +1,330 @@
+package applicationscanning
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/detectify/n5/ip"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	proxySchemeHTTP   = "http"
+	proxySchemeHTTPS  = "https"
+	proxySchemeSOCKS5 = "socks5"
+)
+
+// ProxyFunc selects the proxy to use to reach the address provided, e.g. "example.com:443", over the network provided.
+// Returning a nil URL connects directly. The proxy URL scheme can be "http" or "https" for HTTP CONNECT proxies, or
+// "socks5", and credentials can be provided as the URL user info.
+type ProxyFunc func(ctx context.Context, network, addr string) (*url.URL, error)
+
+// ProxyURL returns a ProxyFunc which always selects the proxy provided.
+func ProxyURL(proxyURL *url.URL) ProxyFunc {
+	return func(context.Context, string, string) (*url.URL, error) {
+		return proxyURL, nil
+	}
+}
+
+// defaultDialer dials from any local address, without a proxy or rate limits.
+var defaultDialer = newDialer(CheckerConf{}, nil)
+
+// dialer opens the connections used by all checks, from the source addresses configured and through a proxy when one
+// is selected.
+type dialer struct {
+	netDialer  *net.Dialer
+	proxy      ProxyFunc
+	localAddrs []net.IP
+	iface      string
+	next       uint32
+	limiter    *rateLimiter
+	tracer     trace.Tracer
+	counters   *counters
+}
+
+func newDialer(conf CheckerConf, limiter *rateLimiter) *dialer {
+	return &dialer{
+		netDialer: &net.Dialer{
+			Timeout:   durationOrDefault(conf.DialTimeout, defaultDialTimeout),
+			KeepAlive: dialKeepAlive,
+		},
+		proxy:      conf.Proxy,
+		localAddrs: conf.LocalAddrs,
+		iface:      conf.Interface,
+		limiter:    limiter,
+		tracer:     newTracer(conf.TracerProvider),
+		counters:   newCounters(conf.MeterProvider),
+	}
+}
+
+// DialContext connects to the address provided, through a proxy when one is selected for it, within a span.
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	ctx, span := d.tracer.Start(ctx, "net.dial", trace.WithAttributes(
+		attribute.String("net.transport", network),
+		attribute.String("net.peer.addr", addr),
+	))
+	conn, err := d.dial(ctx, network, addr)
+	endSpan(span, err)
+	d.counters.dials.Add(ctx, 1, attribute.String("network", network), attribute.String("outcome", outcome(err)))
+	return conn, err
+}
+
+func (d *dialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.proxy == nil {
+		return d.dialLimited(ctx, network, addr)
+	}
+
+	proxyURL, err := d.proxy(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select proxy: %w", err)
+	}
+	if proxyURL == nil {
+		return d.dialLimited(ctx, network, addr)
+	}
+	// hostnames are resolved by the proxy, so only IPs can be limited
+	if host, _, err := net.SplitHostPort(addr); err == nil && ip.IsIP(host) {
+		if err := d.limiter.waitIP(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	switch proxyURL.Scheme {
+	case proxySchemeHTTP, proxySchemeHTTPS:
+		return d.dialConnect(ctx, proxyURL, addr)
+	case proxySchemeSOCKS5:
+		return d.dialSOCKS5(ctx, proxyURL, network, addr)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// dialLimited connects directly to the address provided, once the IP dialed is allowed by the rate limiter. Hostnames
+// are resolved first when IPs are limited, and their IPs are dialed in order until one connects.
+func (d *dialer) dialLimited(ctx context.Context, network, addr string) (net.Conn, error) {
+	if !d.limiter.limitsIPs() {
+		return d.dialDirect(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips := []string{host}
+	if !ip.IsIP(host) {
+		if ips, err = d.resolver().LookupHost(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	var conn net.Conn
+	for _, ipAddress := range ips {
+		if err = d.limiter.waitIP(ctx, ipAddress); err != nil {
+			return nil, err
+		}
+		if conn, err = d.dialDirect(ctx, network, net.JoinHostPort(ipAddress, port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// dialDirect connects to the address provided from the next source address, never through a proxy.
+func (d *dialer) dialDirect(ctx context.Context, network, addr string) (net.Conn, error) {
+	netDialer, err := d.boundDialer(network)
+	if err != nil {
+		return nil, err
+	}
+	return netDialer.DialContext(ctx, network, addr)
+}
+
+// boundDialer returns a net.Dialer bound to the next source address, if any is configured.
+func (d *dialer) boundDialer(network string) (*net.Dialer, error) {
+	ip, err := d.nextLocalIP()
+	if err != nil || ip == nil {
+		return d.netDialer, err
+	}
+
+	bound := *d.netDialer
+	if strings.HasPrefix(network, "udp") {
+		bound.LocalAddr = &net.UDPAddr{IP: ip}
+	} else {
+		bound.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return &bound, nil
+}
+
+// nextLocalIP picks the next source address in a round-robin fashion. Returns nil when the operating system should
+// pick it instead.
+func (d *dialer) nextLocalIP() (net.IP, error) {
+	localAddrs := d.localAddrs
+	if len(localAddrs) == 0 && len(d.iface) > 0 {
+		var err error
+		localAddrs, err = interfaceIPs(d.iface)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(localAddrs) == 0 {
+		return nil, nil
+	}
+
+	next := atomic.AddUint32(&d.next, 1) - 1
+	return localAddrs[next%uint32(len(localAddrs))], nil
+}
+
+// bound reports whether connections are opened from specific source addresses.
+func (d *dialer) bound() bool {
+	return len(d.localAddrs) > 0 || len(d.iface) > 0
+}
+
+// resolver returns the resolver used for native DNS queries, sent from the source addresses configured once the IP of
+// the DNS server is allowed by the rate limiter.
+func (d *dialer) resolver() *net.Resolver {
+	if !d.bound() && !d.limiter.limitsIPs() {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{PreferGo: true, Dial: d.dialResolver}
+}
+
+// dialResolver connects to the DNS server whose address is provided, once its IP is allowed by the rate limiter.
+func (d *dialer) dialResolver(ctx context.Context, network, addr string) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if err := d.limiter.waitIP(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+	return d.dialDirect(ctx, network, addr)
+}
+
+// dialConnect opens a tunnel to the address provided through an HTTP proxy using the CONNECT method.
+func (d *dialer) dialConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := d.dialDirect(ctx, "tcp", proxyAddr(proxyURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+	}
+	if proxyURL.Scheme == proxySchemeHTTPS {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	err = req.Write(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to send proxy CONNECT request: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to read proxy CONNECT response: %w", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %s", response.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	if reader.Buffered() > 0 {
+		// the proxy already relayed data from the address, which must not get lost
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// dialSOCKS5 connects to the address provided through a SOCKS5 proxy.
+func (d *dialer) dialSOCKS5(ctx context.Context, proxyURL *url.URL, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+
+	netDialer, err := d.boundDialer("tcp")
+	if err != nil {
+		return nil, err
+	}
+	socksDialer, err := proxy.SOCKS5("tcp", proxyAddr(proxyURL), auth, netDialer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+	}
+	conn, err := socksDialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect through SOCKS5 proxy: %w", err)
+	}
+	return conn, nil
+}
+
+// proxyAddr returns the address of the proxy, falling back to the default port of its scheme.
+func proxyAddr(proxyURL *url.URL) string {
+	if len(proxyURL.Port()) > 0 {
+		return proxyURL.Host
+	}
+	switch strings.ToLower(proxyURL.Scheme) {
+	case proxySchemeHTTPS:
+		return net.JoinHostPort(proxyURL.Hostname(), "443")
+	case proxySchemeSOCKS5:
+		return net.JoinHostPort(proxyURL.Hostname(), "1080")
+	default:
+		return net.JoinHostPort(proxyURL.Hostname(), "80")
+	}
+}
+
+// interfaceIPs returns the IPv4 addresses of the network interface provided.
+func interfaceIPs(name string) ([]net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find network interface: %w", err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interface addresses: %w", err)
+	}
+
+	var ips []net.IP
+	for _, addr := range addrs {
+		// as application-scanning currently supports only ipv4 addresses, only those are used as source addresses
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no IPv4 address on network interface %s", name)
+	}
+	return ips, nil
+}
+
+// bufferedConn is a net.Conn whose first reads are served from a buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
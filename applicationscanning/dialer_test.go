@@ -0,0 +1,266 @@
+package applicationscanning
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+// newConnectProxy starts an HTTP CONNECT proxy requiring the credentials provided, counting the tunnels opened.
+func newConnectProxy(t *testing.T, user, password string, tunnels *int64) *url.URL {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		proxyReq := &http.Request{Header: http.Header{"Authorization": r.Header["Proxy-Authorization"]}}
+		if u, p, ok := proxyReq.BasicAuth(); !ok || u != user || p != password {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		atomic.AddInt64(tunnels, 1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipe(conn, target)
+	}))
+	t.Cleanup(server.Close)
+
+	proxyURL, _ := url.Parse(server.URL)
+	proxyURL.User = url.UserPassword(user, password)
+	return proxyURL
+}
+
+// newSOCKS5Proxy starts a SOCKS5 proxy requiring the credentials provided, counting the tunnels opened. Only the
+// CONNECT command and IPv4 or domain name addresses are supported.
+func newSOCKS5Proxy(t *testing.T, user, password string, tunnels *int64) *url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				target, err := socks5Handshake(conn, user, password)
+				if err != nil {
+					_ = conn.Close()
+					return
+				}
+				atomic.AddInt64(tunnels, 1)
+				pipe(conn, target)
+			}()
+		}
+	}()
+
+	return &url.URL{Scheme: proxySchemeSOCKS5, Host: listener.Addr().String(), User: url.UserPassword(user, password)}
+}
+
+func socks5Handshake(conn net.Conn, user, password string) (net.Conn, error) {
+	buf := make([]byte, 256)
+	// greeting, asking for username/password authentication
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return nil, err
+	}
+	_, _ = conn.Write([]byte{5, 2})
+
+	// username/password authentication
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	receivedUser := make([]byte, buf[1])
+	_, _ = io.ReadFull(conn, receivedUser)
+	_, _ = io.ReadFull(conn, buf[:1])
+	receivedPassword := make([]byte, buf[0])
+	_, _ = io.ReadFull(conn, receivedPassword)
+	if string(receivedUser) != user || string(receivedPassword) != password {
+		_, _ = conn.Write([]byte{1, 1})
+		return nil, io.EOF
+	}
+	_, _ = conn.Write([]byte{1, 0})
+
+	// connect request
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return nil, err
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		_, _ = io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		_, _ = io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		_, _ = io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return nil, io.EOF
+	}
+	_, _ = io.ReadFull(conn, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return target, nil
+}
+
+func pipe(a, b net.Conn) {
+	go func() {
+		_, _ = io.Copy(a, b)
+		_ = a.Close()
+	}()
+	_, _ = io.Copy(b, a)
+	_ = b.Close()
+}
+
+func newTCPDNSServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &dns.Server{Listener: listener, Handler: dns.HandlerFunc(answerLocalhost)}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return listener.Addr().String()
+}
+
+func answerLocalhost(w dns.ResponseWriter, req *dns.Msg) {
+	res := new(dns.Msg)
+	res.SetReply(req)
+	rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 127.0.0.1")
+	res.Answer = append(res.Answer, rr)
+	_ = w.WriteMsg(res)
+}
+
+func TestChecker_UsesProxies(t *testing.T) {
+	var conns int64
+	serverURL := newCountingServer(t, false, &conns)
+	parsedServerURL, _ := url.Parse(serverURL)
+	port, _ := strconv.Atoi(parsedServerURL.Port())
+	dnsAddr := newTCPDNSServer(t)
+
+	for name, newProxy := range map[string]func(*testing.T, string, string, *int64) *url.URL{
+		"connect": newConnectProxy,
+		"socks5":  newSOCKS5Proxy,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var tunnels int64
+			checker := NewChecker(CheckerConf{Proxy: ProxyURL(newProxy(t, "user", "secret", &tunnels))})
+			defer checker.CloseIdleConnections()
+
+			openPorts, err := checker.Ports(context.TODO(), []string{"127.0.0.1"}, []int{port})
+			require.NoError(t, err)
+			require.Equal(t, []int{port}, openPorts)
+
+			_, _, err = checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+			require.NoError(t, err)
+
+			err = checker.ExternalDNS(context.TODO(), "localhost", []string{dnsAddr})
+			require.NoError(t, err)
+
+			require.Equal(t, int64(3), atomic.LoadInt64(&tunnels))
+		})
+	}
+}
+
+func TestChecker_FailsWithWrongProxyCredentials(t *testing.T) {
+	var conns, tunnels int64
+	serverURL := newCountingServer(t, false, &conns)
+	proxyURL := newConnectProxy(t, "user", "secret", &tunnels)
+	proxyURL.User = url.UserPassword("user", "wrong")
+
+	_, _, err := NewChecker(CheckerConf{Proxy: ProxyURL(proxyURL)}).sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.Error(t, err)
+	require.Equal(t, int64(0), atomic.LoadInt64(&conns))
+}
+
+func TestExternalDNS_OverHTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := new(dns.Msg)
+		require.NoError(t, req.Unpack(body))
+		res := new(dns.Msg)
+		res.SetReply(req)
+		rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 127.0.0.1")
+		res.Answer = append(res.Answer, rr)
+		packed, _ := res.Pack()
+		w.Header().Set("Content-Type", dnsMessageType)
+		_, _ = w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+
+	err := NewChecker(CheckerConf{}).ExternalDNS(context.TODO(), "localhost", []string{server.URL + "/dns-query"})
+	require.NoError(t, err)
+}
+
+func TestChecker_UsesLocalAddrs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on the Linux loopback interface")
+	}
+	var remoteIPs sync.Map
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			remoteIPs.Store(addrIP(conn.RemoteAddr()), true)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	checker := NewChecker(CheckerConf{LocalAddrs: []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.3")}})
+	defer checker.CloseIdleConnections()
+
+	// the first dial uses the first source address, and the next ones alternate
+	_, err := checker.Ports(context.TODO(), []string{"127.0.0.1"}, []int{port})
+	require.NoError(t, err)
+	_, result, err := checker.sendRequest(context.TODO(), server.URL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.3", result.SourceIP)
+
+	for _, ip := range []string{"127.0.0.2", "127.0.0.3"} {
+		_, ok := remoteIPs.Load(ip)
+		require.True(t, ok, ip)
+	}
+}
+
+func TestChecker_UsesInterfaceAddrs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on the Linux loopback interface")
+	}
+	var conns int64
+	serverURL := newCountingServer(t, false, &conns)
+
+	_, result, err := NewChecker(CheckerConf{Interface: "lo"}).sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", result.SourceIP)
+
+	_, _, err = NewChecker(CheckerConf{Interface: "nonexisting0"}).sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.Error(t, err)
+}
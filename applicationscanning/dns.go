@@ -0,0 +1,125 @@
+package applicationscanning
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/detectify/n5/ip"
+	"github.com/miekg/dns"
+)
+
+const (
+	resolverPrefixTCP = "tcp://"
+	resolverPrefixDoH = "https://"
+	dnsMessageType    = "application/dns-message"
+)
+
+// DNSResponseError is the cause of an external DNS resolution failure due to the response of a resolver.
+type DNSResponseError struct {
+	// The resolver which responded
+	Resolver string
+
+	// The response code returned
+	Rcode int
+}
+
+func (e *DNSResponseError) Error() string {
+	if e.Rcode == dns.RcodeSuccess {
+		return fmt.Sprintf("resolver %s: no answer", e.Resolver)
+	}
+	return fmt.Sprintf("resolver %s: %s", e.Resolver, dns.RcodeToString[e.Rcode])
+}
+
+// Permanent reports whether the resolver responded that the name does not exist, which retrying won't change.
+func (e *DNSResponseError) Permanent() bool {
+	return e.Rcode == dns.RcodeNameError
+}
+
+// exchange sends a DNS query to the external resolver provided, which can be a "host:port" address queried over UDP,
+// or over TCP when a proxy is configured, a "tcp://host:port" address queried over TCP, or a DNS-over-HTTPS URL.
+func (c Checker) exchange(ctx context.Context, client *dns.Client, req *dns.Msg, resolver string) (*dns.Msg, error) {
+	switch {
+	case strings.HasPrefix(resolver, resolverPrefixDoH):
+		return c.exchangeDoH(ctx, req, resolver)
+	case strings.HasPrefix(resolver, resolverPrefixTCP):
+		return c.exchangeTCP(ctx, client, req, strings.TrimPrefix(resolver, resolverPrefixTCP))
+	case c.conf.Proxy != nil:
+		return c.exchangeTCP(ctx, client, req, resolver)
+	default:
+		// other queries are sent through the dialer, which limits the IP dialed
+		if host, _, err := net.SplitHostPort(resolver); err == nil && ip.IsIP(host) {
+			if err := c.limiter.waitIP(ctx, host); err != nil {
+				return nil, err
+			}
+		}
+		res, _, err := client.ExchangeContext(ctx, req, resolver)
+		return res, err
+	}
+}
+
+// rcode returns the name of the response code of the DNS response provided, if any.
+func rcode(res *dns.Msg) string {
+	if res == nil {
+		return ""
+	}
+	return dns.RcodeToString[res.Rcode]
+}
+
+// exchangeTCP sends a DNS query over TCP, through a proxy when one is selected for the resolver.
+func (c Checker) exchangeTCP(ctx context.Context, client *dns.Client, req *dns.Msg, addr string) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+
+	conn, err := c.connDialer().DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(dnsTimeout))
+
+	res, _, err := client.ExchangeWithConn(req, &dns.Conn{Conn: conn})
+	return res, err
+}
+
+// exchangeDoH sends a DNS query over HTTPS as described in RFC 8484, through a proxy when one is selected for the
+// resolver.
+func (c Checker) exchangeDoH(ctx context.Context, req *dns.Msg, resolverURL string) (*dns.Msg, error) {
+	packed, err := req.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, resolverURL, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", dnsMessageType)
+	httpReq.Header.Set("Accept", dnsMessageType)
+
+	client := http.Client{Transport: c.httpTransport(), Timeout: dnsTimeout}
+	response, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(response.Body)
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected DNS-over-HTTPS response: %s", response.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+	res := new(dns.Msg)
+	err = res.Unpack(body)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -1,16 +1,20 @@
 package applicationscanning
 
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	endpointresolver "github.com/detectify/endpoint-resolver"
-	"github.com/detectify/n5/domain"
 )
 
 var (
@@ -25,109 +29,368 @@ const (
 	portRetries              = 3
 	httpTimeout              = time.Second * 30
 	httpTimeoutLimit         = time.Second * 4
-	mozillaUserAgent         = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/51.0.2704.103 Safari/537.36"
 	schemeHTTP               = "http"
 	schemeHTTPS              = "https"
 )
 
-func sendRequest(ctx context.Context, requestURL, userAgent string, customHeaders map[string]string) (*url.URL, error) {
-	r, _ := http.NewRequest(http.MethodGet, requestURL, nil)
-	if len(customHeaders) > 0 {
-		for k, v := range customHeaders {
+// request describes the HTTP requests sent to each URL.
+type request struct {
+	method        string
+	userAgent     string
+	customHeaders map[string]string
+	body          []byte
+	auth          endpointresolver.Auth
+	expected      endpointresolver.ExpectedResponse
+}
+
+// newRequest returns the request described by the resolving config provided, or ErrUnsupportedMethod.
+func newRequest(conf endpointresolver.ResolveConf) (request, error) {
+	req := request{
+		method:        strings.ToUpper(conf.Method),
+		userAgent:     conf.UserAgent,
+		customHeaders: conf.CustomHeaders,
+		body:          conf.Body,
+		auth:          conf.Auth,
+		expected:      conf.ExpectedResponse,
+	}
+	switch req.method {
+	case "":
+		req.method = http.MethodGet
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return request{}, endpointresolver.ErrUnsupportedMethod
+	}
+	return req, nil
+}
+
+// checkBodyAssertions returns ErrInvalidExpectedResponse when the request provided expects assertions on bodies which
+// are not read, either because the request is sent with HEAD or because reading bodies is disabled.
+func (c Checker) checkBodyAssertions(req request) error {
+	if len(req.expected.BodyContains) == 0 && req.expected.BodyPattern == nil {
+		return nil
+	}
+	if req.method == http.MethodHead || c.bodyReadLimit() < 0 {
+		return endpointresolver.ErrInvalidExpectedResponse
+	}
+	return nil
+}
+
+// withUserAgent returns a copy of the request sent with the user agent provided.
+func (r request) withUserAgent(userAgent string) request {
+	r.userAgent = userAgent
+	return r
+}
+
+// methodRejected reports whether the status code provided means the method of the request is not supported.
+func methodRejected(statusCode int) bool {
+	return statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented
+}
+
+// sendPathsWithRetry sends a request to each of the URLs provided through sendRequestWithRetry, in order until one
+// responds successfully. Returns the URL requested along with its result, which is the first response received when
+// none was successful, or the last error.
+func (c Checker) sendPathsWithRetry(ctx context.Context, requestURLs []string, req request) (string, *url.URL, endpointresolver.URLResult, error) {
+	var firstURL string
+	var firstResponseURL *url.URL
+	var firstResult endpointresolver.URLResult
+	var err error
+	for _, requestURL := range requestURLs {
+		var responseURL *url.URL
+		var result endpointresolver.URLResult
+		responseURL, result, err = c.sendRequestWithRetry(ctx, requestURL, req)
+		c.log().DebugContext(ctx, "request sent", "stage", endpointresolver.StageHTTP, "url", requestURL,
+			"method", req.method, "user_agent", req.userAgent, "status_code", result.Response.StatusCode,
+			"response_url", result.URL, "attempts", result.Attempts, "error", err)
+		switch {
+		case err == context.Canceled:
+			return requestURL, nil, endpointresolver.URLResult{}, err
+		case err != nil:
+			continue
+		case result.Response.StatusCode < http.StatusBadRequest:
+			return requestURL, responseURL, result, nil
+		case firstResponseURL == nil:
+			firstURL, firstResponseURL, firstResult = requestURL, responseURL, result
+		}
+	}
+	if firstResponseURL != nil {
+		return firstURL, firstResponseURL, firstResult, nil
+	}
+	return requestURLs[len(requestURLs)-1], nil, endpointresolver.URLResult{}, err
+}
+
+// sendRequestWithRetry sends a request through sendRequest according to the HTTP RetryPolicy, and records the number of
+// attempts made in the result.
+func (c Checker) sendRequestWithRetry(ctx context.Context, requestURL string, req request) (*url.URL, endpointresolver.URLResult, error) {
+	var responseURL *url.URL
+	var result endpointresolver.URLResult
+	attempts, err := c.retry(ctx, endpointresolver.StageHTTP, func() (err error) {
+		responseURL, result, err = c.sendRequest(ctx, requestURL, req)
+		return err
+	})
+	result.Attempts = attempts
+	return responseURL, result, err
+}
+
+// sendRequest sends the request provided to the URL provided, following redirects in scope. Requests sent with HEAD are
+// sent again with GET when the method is rejected. Responses failing the assertions expected result in an
+// ErrUnexpectedResponse.
+func (c Checker) sendRequest(ctx context.Context, requestURL string, req request) (*url.URL, endpointresolver.URLResult, error) {
+	method := req.method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var requestBody io.Reader
+	if len(req.body) > 0 {
+		requestBody = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequest(method, requestURL, requestBody)
+	if err != nil {
+		return nil, endpointresolver.URLResult{}, fmt.Errorf("failed on request: %w", err)
+	}
+	if len(req.customHeaders) > 0 {
+		for k, v := range req.customHeaders {
 			r.Header.Add(k, v)
 		}
 	}
-	r.Header.Add("User-Agent", userAgent)
+	r.Header.Add("User-Agent", req.userAgent)
+	if len(req.auth.BearerToken) > 0 && len(r.Header.Get("Authorization")) == 0 {
+		r.Header.Set("Authorization", "Bearer "+req.auth.BearerToken)
+	}
 
-	r = r.WithContext(ctx)
+	result := endpointresolver.URLResult{RequestedURL: requestURL}
+	start := time.Now()
+	timer := newHopTimer(start)
+	trace := timer.clientTrace(func(info httptrace.GotConnInfo) {
+		// the last connection used is the one the final response is received from
+		result.SourceIP = addrIP(info.Conn.LocalAddr())
+	})
 
-	c := http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				Renegotiation:      tls.RenegotiateFreelyAsClient,
-			},
-		},
+	r = r.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	transport := tracingTransport{base: c.authenticatedTransport(req.auth, r.URL), tracer: c.spanTracer()}
+
+	scopePolicy := c.scopePolicy()
+	client := http.Client{
+		Transport: transport,
+		Jar:       cookieJar(r.URL, req.auth.Cookies),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			redirectedReqHost := via[len(via)-1].URL.Hostname()
-			originalReqHost := r.URL.Hostname()
+			result.RedirectChain = append(result.RedirectChain, newRedirectHop(req.Response, timer.next(time.Now())))
 
-			// break after redirecting out of scope
-			if !domain.Contains(originalReqHost, redirectedReqHost) {
+			// break before redirecting out of scope
+			if !scopePolicy.InScope(r.URL.Hostname(), req.URL) {
+				result.RedirectStop = endpointresolver.RedirectStopOutOfScope
+				return http.ErrUseLastResponse
+			}
+			if visited(req.URL, via) {
+				result.RedirectStop = endpointresolver.RedirectStopLoopDetected
 				return http.ErrUseLastResponse
 			}
 			if len(via) > maxRedirects {
+				result.RedirectStop = endpointresolver.RedirectStopLimitReached
 				return http.ErrUseLastResponse
 			}
-			return nil
+			return c.limiter.wait(req.Context(), req.URL.Hostname())
 		},
 		Timeout: httpTimeout,
 	}
-	response, err := c.Do(r)
+	err = c.limiter.wait(ctx, r.URL.Hostname())
 	if err != nil {
-		return nil, fmt.Errorf("failed on request: %w", err)
+		return nil, endpointresolver.URLResult{}, fmt.Errorf("failed on request: %w", err)
 	}
-	return response.Request.URL, nil
-}
+	response, err := client.Do(r)
+	if err != nil {
+		return nil, endpointresolver.URLResult{}, fmt.Errorf("failed on request: %w", err)
+	}
+	defer drainAndClose(response.Body)
 
-func anyWithinTimeLimit(urls map[url.URL]time.Duration) bool {
-	for _, duration := range urls {
-		if duration < httpTimeoutLimit {
-			return true
+	if r.Method == http.MethodHead && methodRejected(response.StatusCode) {
+		c.log().DebugContext(ctx, "method rejected, sending again with GET", "stage", endpointresolver.StageHTTP,
+			"url", requestURL, "status_code", response.StatusCode)
+		req.method = http.MethodGet
+		return c.sendRequest(ctx, requestURL, req)
+	}
+
+	// when redirects stopped early, the last response was already recorded as a hop
+	if result.RedirectStop == endpointresolver.RedirectStopNone {
+		result.RedirectChain = append(result.RedirectChain, newRedirectHop(response, timer.next(time.Now())))
+	}
+	result.URL = response.Request.URL.String()
+	result.Duration = time.Since(start)
+	for _, hop := range result.RedirectChain {
+		result.Timings = result.Timings.Add(hop.Timings)
+	}
+	result.Timings.Total = result.Duration
+	result.Protocol = responseProtocol(response)
+	result.Protocols = []endpointresolver.Protocol{result.Protocol}
+	result.AltServices = parseAltSvc(response.Header.Get("Alt-Svc"))
+	var body []byte
+	result.Response, body = readResponse(response, c.responseHeaders(), c.bodyReadLimit())
+
+	if err := req.expected.Check(response.StatusCode, response.Header, body, result.Duration); err != nil {
+		return nil, endpointresolver.URLResult{}, &endpointresolver.ResolveError{
+			Stage: endpointresolver.StageHTTP,
+			Err:   endpointresolver.ErrUnexpectedResponse,
+			URL:   result.URL,
+			Cause: err,
 		}
 	}
-	return false
+
+	return response.Request.URL, result, nil
 }
 
-func anyWithinScope(urls map[url.URL]time.Duration, hostname string, openPorts []int) bool {
-	for u := range urls {
-		h := u.Hostname()
-		if (h == hostname || domain.Contains(hostname, h)) && portInScope(u.Scheme, u.Port(), openPorts) {
+func newRedirectHop(response *http.Response, timings endpointresolver.Timings) endpointresolver.RedirectHop {
+	return endpointresolver.RedirectHop{
+		URL:        response.Request.URL.String(),
+		StatusCode: response.StatusCode,
+		Location:   response.Header.Get("Location"),
+		Duration:   timings.Total,
+		Timings:    timings,
+	}
+}
+
+func addrIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func visited(u *url.URL, via []*http.Request) bool {
+	for _, req := range via {
+		if req.URL.String() == u.String() {
 			return true
 		}
 	}
 	return false
 }
 
-func portInScope(scheme, port string, ports []int) bool {
-	var portInt int
-	if len(port) > 0 {
-		portInt, _ = strconv.Atoi(port)
-	} else if scheme == schemeHTTP {
-		portInt = 80
-	} else if scheme == schemeHTTPS {
-		portInt = 443
+// timeLimitWarnings returns a warning for each URL whose response is considered slow.
+func timeLimitWarnings(urls map[url.URL]endpointresolver.URLResult, slowResponse SlowResponse) []endpointresolver.Warning {
+	timing := slowResponse.timing()
+	threshold := durationOrDefault(slowResponse.Threshold, httpTimeoutLimit)
+
+	var warnings []endpointresolver.Warning
+	for _, result := range urls {
+		if duration := result.Timings.Get(timing); duration >= threshold {
+			warnings = append(warnings, endpointresolver.Warning{
+				Code:    endpointresolver.WarningHTTPTimeout,
+				Message: fmt.Sprintf("%s timing of %s exceeds %s", timing, duration, threshold),
+				URL:     result.URL,
+			})
+		}
 	}
-	for _, p := range ports {
-		if p == portInt {
-			return true
+	return warnings
+}
+
+// scopeWarnings returns a warning for each URL which redirected out of scope, or was reached out of scope.
+func scopeWarnings(urls map[url.URL]endpointresolver.URLResult, hostname string, openPorts []int, scopePolicy endpointresolver.ScopePolicy) []endpointresolver.Warning {
+	portScope := endpointresolver.PortScope{Ports: openPorts}
+	var warnings []endpointresolver.Warning
+	for u, result := range urls {
+		u := u
+		switch {
+		case result.RedirectStop == endpointresolver.RedirectStopOutOfScope:
+			warnings = append(warnings, endpointresolver.Warning{
+				Code:    endpointresolver.WarningRedirectedOutOfScope,
+				Message: "redirected out of scope to " + lastLocation(result),
+				URL:     result.URL,
+			})
+		case !scopePolicy.InScope(hostname, &u) || !portScope.InScope(hostname, &u):
+			warnings = append(warnings, endpointresolver.Warning{
+				Code:    endpointresolver.WarningRedirectedOutOfScope,
+				Message: "reached out of scope from " + result.RequestedURL,
+				URL:     result.URL,
+			})
 		}
 	}
-	return false
+	return warnings
 }
 
-func convertURLs(urls map[url.URL]time.Duration) []string {
-	var result []string
+// lastLocation returns the Location header of the last hop of the redirect chain.
+func lastLocation(result endpointresolver.URLResult) string {
+	if len(result.RedirectChain) == 0 {
+		return ""
+	}
+	return result.RedirectChain[len(result.RedirectChain)-1].Location
+}
+
+// convertURLs returns the results of the URLs provided, sorted by sortURLs.
+func convertURLs(urls map[url.URL]endpointresolver.URLResult) []endpointresolver.URLResult {
+	keys := make([]url.URL, 0, len(urls))
 	for u := range urls {
-		result = append(result, u.String())
+		keys = append(keys, u)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return urlLess(&keys[i], &keys[j])
+	})
+
+	var results []endpointresolver.URLResult
+	for _, u := range keys {
+		result := urls[u]
+		sort.Strings(result.Aliases)
+		results = append(results, result)
+	}
+	return results
+}
+
+// urlLess orders URLs with HTTPS first, then by hostname, port and the whole URL.
+func urlLess(first, second *url.URL) bool {
+	if (first.Scheme == schemeHTTPS) != (second.Scheme == schemeHTTPS) {
+		return first.Scheme == schemeHTTPS
+	}
+	if first.Hostname() != second.Hostname() {
+		return first.Hostname() < second.Hostname()
 	}
-	return result
+	if firstPort, secondPort := endpointresolver.URLPort(first), endpointresolver.URLPort(second); firstPort != secondPort {
+		return firstPort < secondPort
+	}
+	return first.String() < second.String()
 }
 
-func createURLs(hostname string, port int) []string {
+// sortWarnings orders warnings by the URL affected, then by code.
+func sortWarnings(warnings []endpointresolver.Warning) {
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].URL != warnings[j].URL {
+			return warnings[i].URL < warnings[j].URL
+		}
+		return warnings[i].Code < warnings[j].Code
+	})
+}
+
+// createURLs returns, for each scheme tried on the port provided, the URLs of the paths provided in order. Paths
+// default to "/".
+func createURLs(hostname string, port int, paths []string) [][]string {
+	var bases []string
 	switch port {
 	// for default ports we only send specific schemes, and no need to include port in URL
 	case 80:
-		return []string{fmt.Sprintf("%s://%s/", schemeHTTP, hostname)}
+		bases = []string{fmt.Sprintf("%s://%s", schemeHTTP, hostname)}
 	case 443:
-		return []string{fmt.Sprintf("%s://%s/", schemeHTTPS, hostname)}
+		bases = []string{fmt.Sprintf("%s://%s", schemeHTTPS, hostname)}
 	default:
 		// for any other ports, try both schemes, and explicitly define the port
-		return []string{
-			fmt.Sprintf("%s://%s:%d/", schemeHTTP, hostname, port),
-			fmt.Sprintf("%s://%s:%d/", schemeHTTPS, hostname, port),
+		bases = []string{
+			fmt.Sprintf("%s://%s:%d", schemeHTTP, hostname, port),
+			fmt.Sprintf("%s://%s:%d", schemeHTTPS, hostname, port),
+		}
+	}
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+
+	urls := make([][]string, 0, len(bases))
+	for _, base := range bases {
+		var schemeURLs []string
+		for _, path := range paths {
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+			schemeURLs = append(schemeURLs, base+path)
 		}
+		urls = append(urls, schemeURLs)
 	}
+	return urls
 }
 
 func fetchPorts(endpointPort string, ports []int) ([]int, error) {
@@ -0,0 +1,364 @@
+package applicationscanning
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+// mozillaUserAgent is the user agent test requests are sent with.
+const mozillaUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/51.0.2704.103 Safari/537.36"
+
+// newLocalServer starts a test server and returns its URL using the localhost hostname, so that it is treated as a
+// domain rather than an IP.
+func newLocalServer(t *testing.T, handler http.Handler) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+}
+
+// newLocalServerPort starts a test server as newLocalServer does, and returns its port.
+func newLocalServerPort(t *testing.T, handler http.Handler) int {
+	serverURL, err := url.Parse(newLocalServer(t, handler))
+	require.NoError(t, err)
+	return endpointresolver.URLPort(serverURL)
+}
+
+// newCountingServer starts a test server, optionally over TLS, counting the connections opened to it.
+func newCountingServer(tb testing.TB, useTLS bool, conns *int64) string {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 1024)))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(conns, 1)
+		}
+	}
+	if useTLS {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	tb.Cleanup(server.Close)
+	return strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+}
+
+func TestSendRequest_ReusesConnections(t *testing.T) {
+	var conns int64
+	serverURL := newCountingServer(t, false, &conns)
+	checker := NewChecker(CheckerConf{})
+	defer checker.CloseIdleConnections()
+
+	for i := 0; i < 20; i++ {
+		_, _, err := checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(1), atomic.LoadInt64(&conns))
+}
+
+func BenchmarkSendRequest(b *testing.B) {
+	for _, useTLS := range []bool{false, true} {
+		name := "http"
+		if useTLS {
+			name = "https"
+		}
+		b.Run(name, func(b *testing.B) {
+			var conns int64
+			serverURL := newCountingServer(b, useTLS, &conns)
+			checker := NewChecker(CheckerConf{})
+			defer checker.CloseIdleConnections()
+			goroutines := runtime.NumGoroutine()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			// both should stay flat regardless of b.N when connections are reused and bodies are closed
+			b.ReportMetric(float64(atomic.LoadInt64(&conns)), "conns")
+			b.ReportMetric(float64(runtime.NumGoroutine()-goroutines), "goroutines")
+		})
+	}
+}
+
+func TestSendRequest_RecordsRedirectChain(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.RedirectHandler("/first", http.StatusMovedPermanently))
+	mux.Handle("/first", http.RedirectHandler("/second", http.StatusFound))
+	mux.HandleFunc("/second", func(w http.ResponseWriter, r *http.Request) {})
+	serverURL := newLocalServer(t, mux)
+
+	_, result, err := Checker{}.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, serverURL+"/second", result.URL)
+	require.Equal(t, endpointresolver.RedirectStopNone, result.RedirectStop)
+	require.Equal(t, 3, len(result.RedirectChain))
+	require.Equal(t, http.StatusMovedPermanently, result.RedirectChain[0].StatusCode)
+	require.Equal(t, "/first", result.RedirectChain[0].Location)
+	require.Equal(t, serverURL+"/first", result.RedirectChain[1].URL)
+	require.Equal(t, http.StatusFound, result.RedirectChain[1].StatusCode)
+	require.Equal(t, http.StatusOK, result.RedirectChain[2].StatusCode)
+}
+
+func TestSendRequest_StopsOnRedirectLoop(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.RedirectHandler("/loop", http.StatusFound))
+	mux.Handle("/loop", http.RedirectHandler("/", http.StatusFound))
+	serverURL := newLocalServer(t, mux)
+
+	_, result, err := Checker{}.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, endpointresolver.RedirectStopLoopDetected, result.RedirectStop)
+	require.Equal(t, serverURL+"/loop", result.URL)
+	require.Equal(t, 2, len(result.RedirectChain))
+}
+
+func TestSendRequest_StopsOnRedirectLimit(t *testing.T) {
+	var count int
+	serverURL := newLocalServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		http.Redirect(w, r, "/"+strings.Repeat("a", count), http.StatusFound)
+	}))
+
+	_, result, err := Checker{}.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, endpointresolver.RedirectStopLimitReached, result.RedirectStop)
+	require.Equal(t, maxRedirects+1, len(result.RedirectChain))
+}
+
+func TestSendRequest_StopsBeforeRedirectingOutOfScope(t *testing.T) {
+	var outOfScopeHits int
+	outOfScope := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outOfScopeHits++
+	}))
+	t.Cleanup(outOfScope.Close)
+	serverURL := newLocalServer(t, http.RedirectHandler(outOfScope.URL+"/", http.StatusFound))
+
+	_, result, err := Checker{}.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, endpointresolver.RedirectStopOutOfScope, result.RedirectStop)
+	require.Equal(t, serverURL+"/", result.URL)
+	require.Equal(t, outOfScope.URL+"/", result.RedirectChain[0].Location)
+	require.Equal(t, 0, outOfScopeHits)
+
+	checker := NewChecker(CheckerConf{ScopePolicy: endpointresolver.AnyScopes{
+		endpointresolver.SubdomainScope{},
+		endpointresolver.AllowlistScope{Hosts: []string{"127.0.0.1"}},
+	}})
+	_, result, err = checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, endpointresolver.RedirectStopNone, result.RedirectStop)
+	require.Equal(t, outOfScope.URL+"/", result.URL)
+	require.Equal(t, 1, outOfScopeHits)
+}
+
+func TestHTTPWarnings_ReportsEveryURLAffected(t *testing.T) {
+	urls := map[url.URL]endpointresolver.URLResult{
+		{Scheme: "https", Host: "example.com", Path: "/"}: {
+			URL:     "https://example.com/",
+			Timings: endpointresolver.Timings{Total: httpTimeoutLimit + time.Second},
+		},
+		{Scheme: "http", Host: "example.com", Path: "/"}: {
+			URL:           "http://example.com/",
+			RedirectStop:  endpointresolver.RedirectStopOutOfScope,
+			RedirectChain: []endpointresolver.RedirectHop{{Location: "https://example.org/"}},
+		},
+		{Scheme: "https", Host: "example.net", Path: "/"}: {
+			URL:          "https://example.net/",
+			RequestedURL: "https://example.com:8443/",
+		},
+	}
+
+	warnings := append(scopeWarnings(urls, "example.com", []int{80, 443, 8443}, endpointresolver.SubdomainScope{}), timeLimitWarnings(urls, SlowResponse{})...)
+	require.ElementsMatch(t, []endpointresolver.Warning{
+		{
+			Code:    endpointresolver.WarningRedirectedOutOfScope,
+			Message: "redirected out of scope to https://example.org/",
+			URL:     "http://example.com/",
+		},
+		{
+			Code:    endpointresolver.WarningRedirectedOutOfScope,
+			Message: "reached out of scope from https://example.com:8443/",
+			URL:     "https://example.net/",
+		},
+		{
+			Code:    endpointresolver.WarningHTTPTimeout,
+			Message: "total timing of 5s exceeds 4s",
+			URL:     "https://example.com/",
+		},
+	}, warnings)
+}
+
+func TestConvertURLs_SortsURLs(t *testing.T) {
+	urls := make(map[url.URL]endpointresolver.URLResult)
+	for _, rawURL := range []string{
+		"http://example.com:8080/",
+		"https://www.example.com/",
+		"http://example.com/",
+		"https://example.com:8443/",
+		"https://example.com/",
+		"https://example.com:10443/",
+	} {
+		u, _ := url.Parse(rawURL)
+		urls[*u] = endpointresolver.URLResult{URL: rawURL}
+	}
+
+	var sorted []string
+	for _, result := range convertURLs(urls) {
+		sorted = append(sorted, result.URL)
+	}
+	require.Equal(t, []string{
+		"https://example.com/",
+		"https://example.com:8443/",
+		"https://example.com:10443/",
+		"https://www.example.com/",
+		"http://example.com/",
+		"http://example.com:8080/",
+	}, sorted)
+}
+
+func TestSortWarnings(t *testing.T) {
+	warnings := []endpointresolver.Warning{
+		{Code: endpointresolver.WarningRedirectedOutOfScope, URL: "https://example.org/"},
+		{Code: endpointresolver.WarningRedirectedOutOfScope, URL: "https://example.com/"},
+		{Code: endpointresolver.WarningHTTPTimeout, URL: "https://example.org/"},
+	}
+	sortWarnings(warnings)
+	require.Equal(t, []endpointresolver.Warning{
+		{Code: endpointresolver.WarningRedirectedOutOfScope, URL: "https://example.com/"},
+		{Code: endpointresolver.WarningHTTPTimeout, URL: "https://example.org/"},
+		{Code: endpointresolver.WarningRedirectedOutOfScope, URL: "https://example.org/"},
+	}, warnings)
+}
+
+func TestCreateURLs(t *testing.T) {
+	require.Equal(t, [][]string{{"https://example.com/"}}, createURLs("example.com", 443, nil))
+	require.Equal(t, [][]string{
+		{"http://example.com:8080/app/", "http://example.com:8080/health"},
+		{"https://example.com:8080/app/", "https://example.com:8080/health"},
+	}, createURLs("example.com", 8080, []string{"/app/", "health"}))
+}
+
+func TestHTTP_TriesPathsInOrder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/app/", func(w http.ResponseWriter, r *http.Request) {})
+	serverURL, _ := url.Parse(newLocalServer(t, mux))
+	port := endpointresolver.URLPort(serverURL)
+
+	conf := endpointresolver.ResolveConf{UserAgent: mozillaUserAgent, Paths: []string{"/", "/app/"}}
+	urls, _, err := Checker{}.HTTP(context.TODO(), conf, "localhost", []int{port})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(urls))
+	require.Equal(t, serverURL.String()+"/app/", urls[0].URL)
+	require.Equal(t, http.StatusOK, urls[0].Response.StatusCode)
+
+	// when no path responds successfully, the first response is kept
+	conf.Paths = []string{"/missing", "/other"}
+	urls, _, err = Checker{}.HTTP(context.TODO(), conf, "localhost", []int{port})
+	require.NoError(t, err)
+	require.Equal(t, serverURL.String()+"/missing", urls[0].URL)
+	require.Equal(t, http.StatusNotFound, urls[0].Response.StatusCode)
+}
+
+func TestSendRequest_SendsMethodAndBody(t *testing.T) {
+	var method, body string
+	serverURL := newLocalServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, body = r.Method, string(b)
+	}))
+
+	req, err := newRequest(endpointresolver.ResolveConf{UserAgent: mozillaUserAgent, Method: "options", Body: []byte("ping")})
+	require.NoError(t, err)
+	_, _, err = Checker{}.sendRequest(context.TODO(), serverURL+"/", req)
+	require.NoError(t, err)
+	require.Equal(t, http.MethodOptions, method)
+	require.Equal(t, "ping", body)
+}
+
+func TestSendRequestWithRetry_FallsBackFromHEADToGET(t *testing.T) {
+	var methods []string
+	serverURL := newLocalServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	_, result, err := Checker{}.sendRequestWithRetry(context.TODO(), serverURL+"/", request{method: http.MethodHead, userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, []string{http.MethodHead, http.MethodGet}, methods)
+	require.Equal(t, http.StatusOK, result.Response.StatusCode)
+}
+
+func TestHTTP_RejectsUnsupportedMethod(t *testing.T) {
+	_, _, err := Checker{}.HTTP(context.TODO(), endpointresolver.ResolveConf{Method: http.MethodDelete}, "localhost", []int{80})
+	require.ErrorIs(t, err, endpointresolver.ErrUnsupportedMethod)
+	require.False(t, endpointresolver.IsRetryable(err))
+}
+
+func TestHTTP_ReportsUnexpectedResponse(t *testing.T) {
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("Down for maintenance"))
+	}))
+
+	conf := endpointresolver.ResolveConf{
+		UserAgent: mozillaUserAgent,
+		ExpectedResponse: endpointresolver.ExpectedResponse{
+			StatusCodes:  []endpointresolver.StatusRange{{Min: 200, Max: 299}},
+			BodyContains: "Sign in",
+		},
+	}
+	_, _, err := Checker{}.HTTP(context.TODO(), conf, "localhost", []int{port})
+	require.ErrorIs(t, err, endpointresolver.ErrUnexpectedResponse)
+
+	var resolveErr *endpointresolver.ResolveError
+	require.ErrorAs(t, err, &resolveErr)
+	require.Equal(t, port, resolveErr.Port)
+
+	var unexpectedErr *endpointresolver.UnexpectedResponseError
+	require.ErrorAs(t, err, &unexpectedErr)
+	require.Equal(t, []string{"status code 503 not in 200-299", `body does not contain "Sign in"`}, unexpectedErr.Assertions)
+}
+
+func TestHTTP_RejectsBodyAssertionsWithoutBody(t *testing.T) {
+	var requests int64
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		_, _ = w.Write([]byte("Sign in"))
+	}))
+	expected := endpointresolver.ExpectedResponse{BodyContains: "Sign in"}
+
+	t.Run("HEAD", func(t *testing.T) {
+		conf := endpointresolver.ResolveConf{UserAgent: mozillaUserAgent, Method: http.MethodHead, ExpectedResponse: expected}
+		_, _, err := Checker{}.HTTP(context.TODO(), conf, "localhost", []int{port})
+		require.ErrorIs(t, err, endpointresolver.ErrInvalidExpectedResponse)
+		require.False(t, endpointresolver.IsRetryable(err))
+	})
+
+	t.Run("bodies not read", func(t *testing.T) {
+		checker := NewChecker(CheckerConf{BodyReadLimit: -1})
+		conf := endpointresolver.ResolveConf{UserAgent: mozillaUserAgent, ExpectedResponse: expected}
+		_, _, err := checker.HTTP(context.TODO(), conf, "localhost", []int{port})
+		require.ErrorIs(t, err, endpointresolver.ErrInvalidExpectedResponse)
+	})
+
+	require.Equal(t, int64(0), atomic.LoadInt64(&requests))
+}
@@ -0,0 +1,43 @@
+package applicationscanning
+
+import (
+	"context"
+	"sort"
+)
+
+// Logger is the minimal interface of the logger the inputs, attempts and decisions of checks are logged with, at debug
+// level, which *slog.Logger satisfies. Arguments are alternating keys and values. The values of custom headers and
+// credentials are never logged.
+type Logger interface {
+	DebugContext(ctx context.Context, msg string, args ...any)
+}
+
+// noopLogger logs nothing, and is used when no logger is configured.
+type noopLogger struct{}
+
+func (noopLogger) DebugContext(context.Context, string, ...any) {}
+
+// headerNames returns the names of the headers provided in alphabetical order, so that they can be logged without their
+// values.
+func headerNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c Checker) log() Logger {
+	if c.conf.Logger == nil {
+		return noopLogger{}
+	}
+	return c.conf.Logger
+}
+
+func (c *Resolver) log() Logger {
+	if c.logger == nil {
+		return noopLogger{}
+	}
+	return c.logger
+}
@@ -0,0 +1,62 @@
+package applicationscanning
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger records the messages logged along with their arguments.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+	args     []string
+}
+
+func (l *recordingLogger) DebugContext(_ context.Context, msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+	l.args = append(l.args, fmt.Sprint(args...))
+}
+
+func TestHTTP_LogsWithoutHeaderValues(t *testing.T) {
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	logger := &recordingLogger{}
+	conf := endpointresolver.ResolveConf{
+		UserAgent:     mozillaUserAgent,
+		CustomHeaders: map[string]string{"X-Api-Key": "secret-key"},
+		Auth:          endpointresolver.Auth{BearerToken: "secret-token"},
+	}
+	_, _, err := NewChecker(CheckerConf{Logger: logger}).HTTP(context.TODO(), conf, "localhost", []int{port})
+	require.NoError(t, err)
+
+	require.Equal(t, "sending requests", logger.messages[0])
+	require.Contains(t, logger.messages, "attempt made")
+	require.Contains(t, logger.messages, "request sent")
+	require.Contains(t, logger.args[0], "X-Api-Key")
+	for _, args := range logger.args {
+		require.NotContains(t, args, "secret")
+	}
+}
+
+func TestResolver_WithLoggerReturnsCopy(t *testing.T) {
+	resolver := NewResolver(nil)
+	logger := &recordingLogger{}
+	logged := resolver.WithLogger(logger)
+	require.Nil(t, resolver.logger)
+
+	_, err := resolver.Resolve(context.TODO(), endpointresolver.ResolveConf{Endpoint: "127.0.0.1:1"})
+	require.Error(t, err)
+	require.Empty(t, logger.messages)
+
+	_, err = logged.Resolve(context.TODO(), endpointresolver.ResolveConf{Endpoint: "127.0.0.1:1"})
+	require.Error(t, err)
+	require.Equal(t, "resolving endpoint", logger.messages[0])
+}
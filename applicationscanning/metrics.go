@@ -0,0 +1,59 @@
+package applicationscanning
+
+import (
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+)
+
+const meterName = "github.com/detectify/endpoint-resolver/applicationscanning"
+
+// counters count the DNS queries sent and the TCP dials made.
+type counters struct {
+	dnsQueries instrument.Int64Counter
+	dials      instrument.Int64Counter
+}
+
+// defaultCounters are the no-op counters used without a MeterProvider.
+var defaultCounters = newCounters(nil)
+
+// newCounters returns the counters of the provider provided, or counters which record nothing when none is provided or
+// they could not be created.
+func newCounters(provider metric.MeterProvider) *counters {
+	if provider != nil {
+		if c, err := meterCounters(provider.Meter(meterName)); err == nil {
+			return c
+		}
+	}
+	// instruments of the no-op meter never fail to be created
+	c, _ := meterCounters(metric.NewNoopMeterProvider().Meter(meterName))
+	return c
+}
+
+func meterCounters(meter metric.Meter) (*counters, error) {
+	dnsQueries, err := meter.Int64Counter("endpointresolver.dns.queries",
+		instrument.WithDescription("DNS queries sent, by resolver and outcome"))
+	if err != nil {
+		return nil, err
+	}
+	dials, err := meter.Int64Counter("endpointresolver.dials",
+		instrument.WithDescription("TCP dials made, by outcome"))
+	if err != nil {
+		return nil, err
+	}
+	return &counters{dnsQueries: dnsQueries, dials: dials}, nil
+}
+
+// outcome returns the outcome attribute of a query or dial which returned the error provided.
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+func (c Checker) metricCounters() *counters {
+	if c.counters == nil {
+		return defaultCounters
+	}
+	return c.counters
+}
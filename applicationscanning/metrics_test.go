@@ -0,0 +1,75 @@
+package applicationscanning
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+)
+
+// noopMeter is embedded by countingMeter under another name than Meter, so that it provides itself as well.
+type noopMeter = metric.Meter
+
+// countingMeter provides itself whatever the name, and counts the values added to its Int64 counters by instrument name
+// and attributes.
+type countingMeter struct {
+	noopMeter
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newCountingMeter() *countingMeter {
+	return &countingMeter{noopMeter: metric.NewNoopMeterProvider().Meter(""), counts: make(map[string]int64)}
+}
+
+func (m *countingMeter) Meter(string, ...metric.MeterOption) metric.Meter {
+	return m
+}
+
+func (m *countingMeter) Int64Counter(name string, options ...instrument.Int64Option) (instrument.Int64Counter, error) {
+	counter, err := m.noopMeter.Int64Counter(name, options...)
+	return countingCounter{Int64Counter: counter, meter: m, name: name}, err
+}
+
+type countingCounter struct {
+	instrument.Int64Counter
+	meter *countingMeter
+	name  string
+}
+
+func (c countingCounter) Add(_ context.Context, incr int64, attrs ...attribute.KeyValue) {
+	c.meter.mu.Lock()
+	defer c.meter.mu.Unlock()
+	key := c.name
+	for _, attr := range attrs {
+		key += " " + string(attr.Key) + "=" + attr.Value.Emit()
+	}
+	c.meter.counts[key] += incr
+}
+
+func TestChecker_CountsDNSQueriesAndDials(t *testing.T) {
+	dnsAddr := newTCPDNSServer(t)
+	port := newLocalServerPort(t, nil)
+	meter := newCountingMeter()
+	checker := NewChecker(CheckerConf{MeterProvider: meter, RetryPolicies: RetryPolicies{
+		ExternalDNS: RetryPolicy{MaxAttempts: 1},
+		Ports:       RetryPolicy{MaxAttempts: 1},
+	}})
+	defer checker.CloseIdleConnections()
+
+	require.NoError(t, checker.ExternalDNS(context.TODO(), "localhost", []string{"tcp://" + dnsAddr}))
+	_, err := checker.NativeDNS(context.TODO(), "localhost")
+	require.NoError(t, err)
+	_, _ = checker.Ports(context.TODO(), []string{"127.0.0.1"}, []int{port, 1})
+
+	require.Equal(t, map[string]int64{
+		"endpointresolver.dns.queries resolver=tcp://" + dnsAddr + " outcome=ok": 1,
+		"endpointresolver.dns.queries resolver=native outcome=ok":                1,
+		"endpointresolver.dials network=tcp outcome=ok":                          2,
+		"endpointresolver.dials network=tcp outcome=error":                       1,
+	}, meter.counts)
+}
@@ -0,0 +1,189 @@
+package applicationscanning
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/quic-go/quic-go"
+)
+
+// quicHandshakeTimeout limits how long a QUIC handshake may take.
+const quicHandshakeTimeout = time.Second * 5
+
+// QUICProber confirms that an address supports HTTP/3 by performing a QUIC handshake with it over UDP.
+type QUICProber interface {
+	// ProbeQUIC performs a QUIC handshake with the address provided, using serverName for SNI and "h3" for ALPN, and
+	// returns an error if it did not complete.
+	ProbeQUIC(ctx context.Context, serverName, addr string) error
+}
+
+// ProbeQUIC implements QUICProber, performing the handshake from the next source address. QUIC handshakes are never
+// proxied.
+func (d *dialer) ProbeQUIC(ctx context.Context, serverName, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	ips, err := d.resolver().LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		return errors.New("no IPv4 address for " + host)
+	}
+	udpPort, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	}
+	localIP, err := d.nextLocalIP()
+	if err != nil {
+		return err
+	}
+	packetConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: localIP})
+	if err != nil {
+		return err
+	}
+	defer packetConn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, quicHandshakeTimeout)
+	defer cancel()
+	conn, err := quic.Dial(ctx, packetConn, &net.UDPAddr{IP: ips[0], Port: udpPort}, &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         serverName,
+		NextProtos:         []string{string(endpointresolver.ProtocolHTTP3)},
+	}, &quic.Config{HandshakeIdleTimeout: quicHandshakeTimeout})
+	if err != nil {
+		return err
+	}
+	return conn.CloseWithError(0, "")
+}
+
+// checkProtocols probes the protocols supported by the URLs found when enabled, and discards the ones not supporting
+// the required protocol, if any.
+func (c Checker) checkProtocols(ctx context.Context, urls map[url.URL]endpointresolver.URLResult) error {
+	if !c.conf.ProbeProtocols && len(c.conf.RequiredProtocol) == 0 {
+		return nil
+	}
+
+	for u, result := range urls {
+		u := u
+		result.Protocols = c.probeProtocols(ctx, &u, result)
+		urls[u] = result
+	}
+
+	if len(c.conf.RequiredProtocol) == 0 {
+		return nil
+	}
+	for u, result := range urls {
+		if !hasProtocol(result.Protocols, c.conf.RequiredProtocol) {
+			delete(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		return endpointresolver.ErrRequiredProtocolUnsupported
+	}
+	return nil
+}
+
+// probeProtocols returns the protocols the URL provided supports, starting with the ones already known.
+func (c Checker) probeProtocols(ctx context.Context, u *url.URL, result endpointresolver.URLResult) []endpointresolver.Protocol {
+	protocols := result.Protocols
+	addr := net.JoinHostPort(u.Hostname(), strconv.Itoa(endpointresolver.URLPort(u)))
+
+	if u.Scheme == schemeHTTPS {
+		for _, protocol := range []endpointresolver.Protocol{endpointresolver.ProtocolHTTP1, endpointresolver.ProtocolHTTP2} {
+			if !hasProtocol(protocols, protocol) && c.supportsALPN(ctx, u.Hostname(), addr, protocol) {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+
+	for _, altService := range result.AltServices {
+		if altService.Protocol != endpointresolver.ProtocolHTTP3 {
+			continue
+		}
+		host, port, err := net.SplitHostPort(altService.Authority)
+		if err != nil {
+			continue
+		}
+		if len(host) == 0 {
+			host = u.Hostname()
+		}
+		if c.quicProber().ProbeQUIC(ctx, u.Hostname(), net.JoinHostPort(host, port)) == nil {
+			return append(protocols, endpointresolver.ProtocolHTTP3)
+		}
+	}
+	return protocols
+}
+
+// supportsALPN reports whether a TLS handshake offering only the protocol provided through ALPN negotiates it.
+func (c Checker) supportsALPN(ctx context.Context, serverName, addr string, protocol endpointresolver.Protocol) bool {
+	rawConn, err := c.connDialer().DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false
+	}
+	conn := tls.Client(rawConn, &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         serverName,
+		NextProtos:         []string{string(protocol)},
+	})
+	defer conn.Close()
+	if err = conn.HandshakeContext(ctx); err != nil {
+		return false
+	}
+
+	negotiated := conn.ConnectionState().NegotiatedProtocol
+	// servers not supporting ALPN at all only speak HTTP/1.1
+	return negotiated == string(protocol) || (len(negotiated) == 0 && protocol == endpointresolver.ProtocolHTTP1)
+}
+
+func (c Checker) quicProber() QUICProber {
+	if c.conf.QUICProber == nil {
+		return c.connDialer()
+	}
+	return c.conf.QUICProber
+}
+
+func responseProtocol(response *http.Response) endpointresolver.Protocol {
+	if response.ProtoMajor == 2 {
+		return endpointresolver.ProtocolHTTP2
+	}
+	return endpointresolver.ProtocolHTTP1
+}
+
+// parseAltSvc parses the alternative services advertised in an Alt-Svc header value, e.g.
+// `h3=":443"; ma=86400, h3-29=":443"`.
+func parseAltSvc(header string) []endpointresolver.AltService {
+	var altServices []endpointresolver.AltService
+	for _, entry := range strings.Split(header, ",") {
+		// parameters such as the max age are not relevant
+		entry = strings.TrimSpace(strings.SplitN(entry, ";", 2)[0])
+		protocol, authority, found := strings.Cut(entry, "=")
+		if !found {
+			// the "clear" value, or garbage
+			continue
+		}
+		altServices = append(altServices, endpointresolver.AltService{
+			Protocol:  endpointresolver.Protocol(strings.TrimSpace(protocol)),
+			Authority: strings.Trim(strings.TrimSpace(authority), `"`),
+		})
+	}
+	return altServices
+}
+
+func hasProtocol(protocols []endpointresolver.Protocol, protocol endpointresolver.Protocol) bool {
+	for _, p := range protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
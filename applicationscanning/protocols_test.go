@@ -0,0 +1,120 @@
+package applicationscanning
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/require"
+)
+
+type quicProberMock struct {
+	addrs []string
+	err   error
+}
+
+func (q *quicProberMock) ProbeQUIC(_ context.Context, _, addr string) error {
+	q.addrs = append(q.addrs, addr)
+	return q.err
+}
+
+func newHTTP2Server(t *testing.T) string {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", `h3=":8443"; ma=86400, h3-29=":8443", clear`)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+}
+
+func probeURL(t *testing.T, checker Checker, requestURL string) (map[url.URL]endpointresolver.URLResult, error) {
+	responseURL, result, err := checker.sendRequest(context.TODO(), requestURL, request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	urls := map[url.URL]endpointresolver.URLResult{*responseURL: result}
+	return urls, checker.checkProtocols(context.TODO(), urls)
+}
+
+func TestSendRequest_NegotiatesHTTP2(t *testing.T) {
+	serverURL := newHTTP2Server(t)
+
+	_, result, err := NewChecker(CheckerConf{}).sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, endpointresolver.ProtocolHTTP2, result.Protocol)
+	require.Equal(t, []endpointresolver.Protocol{endpointresolver.ProtocolHTTP2}, result.Protocols)
+	require.Equal(t, []endpointresolver.AltService{
+		{Protocol: endpointresolver.ProtocolHTTP3, Authority: ":8443"},
+		{Protocol: "h3-29", Authority: ":8443"},
+	}, result.AltServices)
+}
+
+func TestCheckProtocols_ProbesALPNAndQUIC(t *testing.T) {
+	serverURL := newHTTP2Server(t)
+	quicProber := &quicProberMock{}
+
+	urls, err := probeURL(t, NewChecker(CheckerConf{ProbeProtocols: true, QUICProber: quicProber}), serverURL+"/")
+	require.NoError(t, err)
+	for _, result := range urls {
+		require.ElementsMatch(t, []endpointresolver.Protocol{
+			endpointresolver.ProtocolHTTP1,
+			endpointresolver.ProtocolHTTP2,
+			endpointresolver.ProtocolHTTP3,
+		}, result.Protocols)
+	}
+	require.Equal(t, []string{"localhost:8443"}, quicProber.addrs)
+}
+
+func TestCheckProtocols_DiscardsURLsNotSupportingRequiredProtocol(t *testing.T) {
+	serverURL := newHTTP2Server(t)
+	quicProber := &quicProberMock{err: errors.New("no QUIC")}
+
+	urls, err := probeURL(t, NewChecker(CheckerConf{RequiredProtocol: endpointresolver.ProtocolHTTP2, QUICProber: quicProber}), serverURL+"/")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(urls))
+
+	urls, err = probeURL(t, NewChecker(CheckerConf{RequiredProtocol: endpointresolver.ProtocolHTTP3, QUICProber: quicProber}), serverURL+"/")
+	require.ErrorIs(t, err, endpointresolver.ErrRequiredProtocolUnsupported)
+	require.Equal(t, 0, len(urls))
+}
+
+// newQUICServer starts a QUIC listener negotiating HTTP/3, and returns its address.
+func newQUICServer(t *testing.T) string {
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsServer.Close()
+	listener, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: tlsServer.TLS.Certificates,
+		NextProtos:   []string{string(endpointresolver.ProtocolHTTP3)},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				<-conn.Context().Done()
+			}()
+		}
+	}()
+	return strings.Replace(listener.Addr().String(), "127.0.0.1", "localhost", 1)
+}
+
+func TestProbeQUIC(t *testing.T) {
+	addr := newQUICServer(t)
+
+	require.NoError(t, newDialer(CheckerConf{}, nil).ProbeQUIC(context.TODO(), "localhost", addr))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	require.Error(t, newDialer(CheckerConf{}, nil).ProbeQUIC(ctx, "localhost", "localhost:1"))
+}
@@ -0,0 +1,146 @@
+package applicationscanning
+
+import (
+	"context"
+	"sync"
+
+	"github.com/detectify/n5/domain"
+	"github.com/detectify/n5/ip"
+	"golang.org/x/time/rate"
+)
+
+// sweepThreshold is the number of per-destination limiters above which idle ones are discarded.
+const sweepThreshold = 1024
+
+// RateLimit configures a token bucket limiter.
+type RateLimit struct {
+	// Rate is the number of events allowed per second. Zero disables the limiter.
+	Rate float64
+
+	// Burst is the number of events allowed at once. Defaults to 1.
+	Burst int
+}
+
+// RateLimits configures the limiters applied to DNS queries, TCP dials and HTTP requests. DNS queries are limited by
+// the hostname queried and the IP of the resolver, port checks by the hostname of the endpoint and the IP dialed, and
+// HTTP requests by the hostname requested, including redirects, and the IP of each connection opened. The IPs dialed
+// through a proxy are only known, and so limited, when dialing an IP.
+type RateLimits struct {
+	// Global limits all events
+	Global RateLimit
+
+	// PerIP limits events per destination IP address
+	PerIP RateLimit
+
+	// PerDomain limits events per destination registrable domain
+	PerDomain RateLimit
+}
+
+// rateLimiter applies RateLimits. It is shared by all copies of a Checker, and so across concurrent resolutions.
+type rateLimiter struct {
+	global    *rate.Limiter
+	perIP     *keyedLimiter
+	perDomain *keyedLimiter
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	return &rateLimiter{
+		global:    newLimiter(limits.Global),
+		perIP:     &keyedLimiter{limit: limits.PerIP, limiters: make(map[string]*rate.Limiter)},
+		perDomain: &keyedLimiter{limit: limits.PerDomain, limiters: make(map[string]*rate.Limiter)},
+	}
+}
+
+// wait blocks until an event to the hostname provided is allowed by the global limiter and the one of its registrable
+// domain. Events to an IP are only limited globally, as the IPs dialed are limited by waitIP.
+func (l *rateLimiter) wait(ctx context.Context, hostname string) error {
+	if l == nil {
+		return nil
+	}
+
+	if l.global != nil {
+		if err := l.global.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	if ip.IsIP(hostname) {
+		return nil
+	}
+	key := domain.Apex(hostname)
+	if len(key) == 0 {
+		key = hostname
+	}
+	return l.perDomain.wait(ctx, key)
+}
+
+// waitIP blocks until a connection or DNS query to the IP provided is allowed by the limiter of the IP.
+func (l *rateLimiter) waitIP(ctx context.Context, ipAddress string) error {
+	if l == nil {
+		return nil
+	}
+	return l.perIP.wait(ctx, ipAddress)
+}
+
+// limitsIPs reports whether the IPs dialed are rate limited.
+func (l *rateLimiter) limitsIPs() bool {
+	return l != nil && l.perIP.limit.Rate > 0
+}
+
+// keyedLimiter holds a limiter per key, created on demand.
+type keyedLimiter struct {
+	limit    RateLimit
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func (k *keyedLimiter) wait(ctx context.Context, key string) error {
+	if k.limit.Rate <= 0 {
+		return nil
+	}
+
+	k.mu.Lock()
+	limiter, ok := k.limiters[key]
+	if !ok {
+		if len(k.limiters) >= sweepThreshold {
+			k.sweep()
+		}
+		limiter = newLimiter(k.limit)
+		k.limiters[key] = limiter
+	}
+	k.mu.Unlock()
+
+	return limiter.Wait(ctx)
+}
+
+// sweep discards limiters whose bucket is full, as they behave the same as new ones.
+func (k *keyedLimiter) sweep() {
+	for key, limiter := range k.limiters {
+		if limiter.Tokens() >= float64(limiter.Burst()) {
+			delete(k.limiters, key)
+		}
+	}
+}
+
+func newLimiter(limit RateLimit) *rate.Limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit.Rate), intOrDefault(limit.Burst, 1))
+}
+
+type hostnameKey struct{}
+
+// withHostname returns a context in which the IPs checked are known to be the ones of the hostname provided, so that
+// port checks are limited by its registrable domain.
+func withHostname(ctx context.Context, hostname string) context.Context {
+	return context.WithValue(ctx, hostnameKey{}, hostname)
+}
+
+// contextHostname returns the hostname the IPs checked are the ones of, or the IP provided when it is not known.
+func contextHostname(ctx context.Context, ipAddress string) string {
+	if hostname, ok := ctx.Value(hostnameKey{}).(string); ok && len(hostname) > 0 {
+		return hostname
+	}
+	return ipAddress
+}
@@ -0,0 +1,98 @@
+package applicationscanning
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_LimitsPerDestination(t *testing.T) {
+	limiter := newRateLimiter(RateLimits{
+		PerIP:     RateLimit{Rate: 20},
+		PerDomain: RateLimit{Rate: 20},
+	})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, limiter.waitIP(context.TODO(), "127.0.0.1"))
+		require.NoError(t, limiter.wait(context.TODO(), "www.example.com"))
+		// subdomains share the limiter of their registrable domain
+		require.NoError(t, limiter.wait(context.TODO(), "api.example.com"))
+	}
+	elapsed := time.Since(start)
+
+	// 5 events on the IP and 10 on the domain, minus the burst of 1, at 20 per second
+	require.GreaterOrEqual(t, elapsed, 400*time.Millisecond)
+}
+
+func TestRateLimiter_LimitsGlobally(t *testing.T) {
+	limiter := newRateLimiter(RateLimits{Global: RateLimit{Rate: 20, Burst: 2}})
+
+	start := time.Now()
+	for _, destination := range []string{"127.0.0.1", "127.0.0.2", "example.com", "example.org"} {
+		require.NoError(t, limiter.wait(context.TODO(), destination))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestRateLimiter_StopsWaitingOnCancel(t *testing.T) {
+	limiter := newRateLimiter(RateLimits{PerIP: RateLimit{Rate: 0.1}})
+	require.NoError(t, limiter.waitIP(context.TODO(), "127.0.0.1"))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, limiter.waitIP(ctx, "127.0.0.1"))
+}
+
+func TestRateLimiter_DiscardsIdleLimiters(t *testing.T) {
+	limiter := newRateLimiter(RateLimits{PerDomain: RateLimit{Rate: 1000}})
+	for i := 0; i < sweepThreshold; i++ {
+		require.NoError(t, limiter.wait(context.TODO(), strconv.Itoa(i)+".example"))
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	require.NoError(t, limiter.wait(context.TODO(), "example.com"))
+	require.Equal(t, 1, len(limiter.perDomain.limiters))
+}
+
+func TestRateLimiter_NilLimiterAllowsEverything(t *testing.T) {
+	var limiter *rateLimiter
+	require.NoError(t, limiter.wait(context.TODO(), "example.com"))
+	require.NoError(t, limiter.waitIP(context.TODO(), "127.0.0.1"))
+}
+
+func TestChecker_LimitsIPsOfDomains(t *testing.T) {
+	serverURL := newLocalServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// each request opens a connection of its own
+		w.Header().Set("Connection", "close")
+	}))
+	checker := NewChecker(CheckerConf{RateLimits: RateLimits{PerIP: RateLimit{Rate: 20}}})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, _, err := checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+		require.NoError(t, err)
+	}
+
+	// 5 connections to the IP of localhost, minus the burst of 1, at 20 per second
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	require.Contains(t, checker.limiter.perIP.limiters, "127.0.0.1")
+	require.NotContains(t, checker.limiter.perIP.limiters, "localhost")
+}
+
+func TestPorts_LimitsDomainOfIPs(t *testing.T) {
+	port := newLocalServerPort(t, http.NotFoundHandler())
+	checker := NewChecker(CheckerConf{RateLimits: RateLimits{
+		PerIP:     RateLimit{Rate: 1000},
+		PerDomain: RateLimit{Rate: 1000},
+	}})
+
+	_, err := checker.Ports(withHostname(context.TODO(), "www.example.com"), []string{"127.0.0.1"}, []int{port})
+	require.NoError(t, err)
+	require.Contains(t, checker.limiter.perDomain.limiters, "example.com")
+	require.Contains(t, checker.limiter.perIP.limiters, "127.0.0.1")
+}
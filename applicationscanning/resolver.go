@@ -13,6 +13,7 @@ type Resolver struct {
 	// externalDNS is a slice of IPs for external DNS resolvers that we can use.
 	externalDNS []string
 	checker     endpointresolver.Checker
+	logger      Logger
 }
 
 // NewResolver generates and returns a Resolver pointer instance including any external DNS resolver configuration.
@@ -26,9 +27,26 @@ func NewResolverWithCheckers(externalDNS []string, checker endpointresolver.Chec
 	return &Resolver{externalDNS: externalDNS, checker: checker}
 }
 
-// Resolve does a full resolution check by consequently executing open ports, DNS and HTTP checks. Returns back a list
-// of valid URLs, or an error.
-func (c *Resolver) Resolve(ctx context.Context, conf endpointresolver.ResolveConf) (urls []string, err error) {
+// WithLogger returns a copy of the Resolver logging the inputs and outcome of each stage of the resolutions with the
+// logger provided, at debug level.
+func (c *Resolver) WithLogger(logger Logger) *Resolver {
+	resolver := *c
+	resolver.logger = logger
+	return &resolver
+}
+
+// Resolve does a full resolution check by consequently executing open ports, DNS and HTTP checks. Returns back a Result
+// listing the valid URLs, or an error.
+func (c *Resolver) Resolve(ctx context.Context, conf endpointresolver.ResolveConf) (result endpointresolver.Result, err error) {
+	ctx, attempts := withAttemptsRecorder(ctx)
+	defer func() {
+		result.Attempts = attempts.snapshot()
+		c.log().DebugContext(ctx, "resolution finished", "endpoint", conf.Endpoint, "urls", len(result.URLs),
+			"warnings", len(result.Warnings), "attempts", result.Attempts, "error", err)
+	}()
+	c.log().DebugContext(ctx, "resolving endpoint", "endpoint", conf.Endpoint, "ports", conf.Ports,
+		"user_agent", conf.UserAgent, "custom_headers", headerNames(conf.CustomHeaders))
+
 	endpointParts := strings.Split(conf.Endpoint, ":")
 	hostname := endpointParts[0]
 	var portStr string
@@ -38,7 +56,7 @@ func (c *Resolver) Resolve(ctx context.Context, conf endpointresolver.ResolveCon
 
 	ports, err := fetchPorts(portStr, conf.Ports)
 	if err != nil {
-		return nil, err
+		return endpointresolver.Result{}, err
 	}
 
 	isDomain := domain.IsDomainName(hostname)
@@ -50,17 +68,25 @@ func (c *Resolver) Resolve(ctx context.Context, conf endpointresolver.ResolveCon
 	// If it is a domain (not an IP), we'll do some DNS checks
 	if isDomain {
 		err = c.checker.ExternalDNS(ctx, hostname, c.externalDNS)
+		c.log().DebugContext(ctx, "stage finished", "stage", endpointresolver.StageExternalDNS, "hostname", hostname,
+			"resolvers", c.externalDNS, "error", err)
 		if err != nil {
-			return nil, err
+			return endpointresolver.Result{}, err
 		}
 
 		ips, err = c.checker.NativeDNS(ctx, hostname)
+		c.log().DebugContext(ctx, "stage finished", "stage", endpointresolver.StageNativeDNS, "hostname", hostname,
+			"ips", ips, "error", err)
 		if err != nil {
-			return nil, err
+			return endpointresolver.Result{}, err
 		}
 
 		if len(ips) == 0 {
-			return nil, endpointresolver.ErrNoIPForEndpoint
+			return endpointresolver.Result{}, &endpointresolver.ResolveError{
+				Stage:    endpointresolver.StageNativeDNS,
+				Err:      endpointresolver.ErrNoIPForEndpoint,
+				Hostname: hostname,
+			}
 		}
 	}
 
@@ -70,19 +96,24 @@ func (c *Resolver) Resolve(ctx context.Context, conf endpointresolver.ResolveCon
 		// as application-scanning currently supports only ipv4 addresses, this is why we won't consider ipv6 addresses
 		// as valid input.
 		if !ip.IsIPv4(hostname) {
-			return nil, endpointresolver.ErrIPV6Unsupported
+			return endpointresolver.Result{}, endpointresolver.ErrIPV6Unsupported
 		}
 	}
 
 	// it's not a domain, and it's not an IP, so erroring
 	if !isDomain && !isIP {
-		return nil, endpointresolver.ErrInvalidEndpoint
+		return endpointresolver.Result{}, endpointresolver.ErrInvalidEndpoint
 	}
 
-	openPorts, err := c.checker.Ports(ctx, ips, ports)
+	openPorts, err := c.checker.Ports(withHostname(ctx, hostname), ips, ports)
+	c.log().DebugContext(ctx, "stage finished", "stage", endpointresolver.StagePorts, "ips", ips, "ports", ports,
+		"open_ports", openPorts, "error", err)
 	if err != nil {
-		return nil, err
+		return endpointresolver.Result{}, err
 	}
 
-	return c.checker.HTTP(ctx, conf.UserAgent, hostname, conf.CustomHeaders, openPorts)
+	urls, warnings, err := c.checker.HTTP(ctx, conf, hostname, openPorts)
+	c.log().DebugContext(ctx, "stage finished", "stage", endpointresolver.StageHTTP, "hostname", hostname,
+		"urls", len(urls), "warnings", len(warnings), "error", err)
+	return endpointresolver.Result{URLs: urls, Warnings: warnings}, err
 }
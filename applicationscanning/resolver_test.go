@@ -9,22 +9,22 @@ import (
 )
 
 func TestResolve_ResolvingDomain(t *testing.T) {
-	urls, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
+	result, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
 		Endpoint:  "detectify.com",
 		UserAgent: "Mozilla/5.0 (compatible; Detectify)",
 	})
 	require.NoError(t, err)
-	require.Equal(t, 1, len(urls))
+	require.Equal(t, 1, len(result.URLs))
 }
 
 func TestResolve_ResolvingDomainWithOpenPort(t *testing.T) {
-	urls, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
+	result, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
 		Endpoint:  "detectify.com",
 		UserAgent: "Mozilla/5.0 (compatible; Detectify)",
 		Ports:     []int{443},
 	})
 	require.NoError(t, err)
-	require.Equal(t, 1, len(urls))
+	require.Equal(t, 1, len(result.URLs))
 }
 
 func TestResolve_ResolvingDomainWithClosedPort(t *testing.T) {
@@ -34,7 +34,7 @@ func TestResolve_ResolvingDomainWithClosedPort(t *testing.T) {
 		Ports:     []int{8080},
 	})
 	require.Error(t, err)
-	require.Equal(t, endpointresolver.ErrNoOpenPort, err)
+	require.ErrorIs(t, err, endpointresolver.ErrNoOpenPort)
 }
 
 func TestResolve_NonResolvingDomainName(t *testing.T) {
@@ -43,34 +43,38 @@ func TestResolve_NonResolvingDomainName(t *testing.T) {
 		UserAgent: "Mozilla/5.0 (compatible; Detectify)",
 	})
 	require.Error(t, err)
-	require.Equal(t, endpointresolver.ErrThirdPartyDNSResolutionFailure, err)
+	require.ErrorIs(t, err, endpointresolver.ErrThirdPartyDNSResolutionFailure)
 }
 
 func TestResolve_DomainRedirectingToWWW(t *testing.T) {
 	// this domain is not considered to be redirecting because it redirects to the www-version of it
-	urls, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
+	result, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
 		Endpoint:  "koslib.com",
 		UserAgent: "Mozilla/5.0 (compatible; Detectify)",
 	})
 	require.NoError(t, err)
-	require.Equal(t, 1, len(urls))
+	require.Equal(t, 1, len(result.URLs))
 }
 
 func TestResolve_RedirectingOutsideScope(t *testing.T) {
-	urls, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
+	result, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
 		Endpoint:  "302.koslib.com",
 		UserAgent: "Mozilla/5.0 (compatible; Detectify)",
 	})
-	require.Equal(t, endpointresolver.WarnRedirectedOutOfScope, err)
-	require.Equal(t, 1, len(urls))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.URLs))
+	require.Equal(t, 1, len(result.Warnings))
+	require.Equal(t, endpointresolver.WarningRedirectedOutOfScope, result.Warnings[0].Code)
 }
 
 func TestResolve_RedirectingOutsideScopeDueToOtherSchemeNotInScope(t *testing.T) {
-	urls, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
+	result, err := NewResolver([]string{"8.8.8.8:53"}).Resolve(context.TODO(), endpointresolver.ResolveConf{
 		Endpoint:  "detectify.com",
 		UserAgent: "Mozilla/5.0 (compatible; Detectify)",
 		Ports:     []int{80},
 	})
-	require.Equal(t, endpointresolver.WarnRedirectedOutOfScope, err)
-	require.Equal(t, 1, len(urls))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(result.URLs))
+	require.Equal(t, 1, len(result.Warnings))
+	require.Equal(t, endpointresolver.WarningRedirectedOutOfScope, result.Warnings[0].Code)
 }
@@ -0,0 +1,89 @@
+package applicationscanning
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const defaultBodyReadLimit = 64 << 10
+
+// defaultResponseHeaders are the response headers reported when none are configured.
+var defaultResponseHeaders = []string{"Server", "X-Powered-By", "Via"}
+
+// readResponse returns the metadata of the response provided along with the bytes read of its body, reading up to limit
+// bytes. A negative limit skips reading the body.
+func readResponse(response *http.Response, headers []string, limit int64) (endpointresolver.ResponseMetadata, []byte) {
+	metadata := endpointresolver.ResponseMetadata{
+		StatusCode:    response.StatusCode,
+		ContentType:   response.Header.Get("Content-Type"),
+		ContentLength: response.ContentLength,
+		Server:        response.Header.Get("Server"),
+	}
+
+	for _, name := range headers {
+		values := response.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if metadata.Headers == nil {
+			metadata.Headers = make(map[string]string)
+		}
+		metadata.Headers[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+	}
+
+	if limit < 0 {
+		metadata.Providers = detectProviders(response, nil)
+		return metadata, nil
+	}
+	// a body failing to be read entirely is still described by what was read of it
+	body, _ := io.ReadAll(io.LimitReader(response.Body, limit))
+	hash := sha256.Sum256(body)
+	metadata.BodyHash = hex.EncodeToString(hash[:])
+	metadata.BodyBytesRead = int64(len(body))
+	metadata.Title = htmlTitle(body)
+	metadata.Providers = detectProviders(response, body)
+	metadata.ChallengeMarkers = findChallengeMarkers(body)
+
+	return metadata, body
+}
+
+// htmlTitle returns the content of the first title element of the HTML document provided, if any.
+func htmlTitle(body []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); atom.Lookup(name) != atom.Title {
+				continue
+			}
+			if tokenizer.Next() != html.TextToken {
+				return ""
+			}
+			return strings.Join(strings.Fields(string(tokenizer.Text())), " ")
+		}
+	}
+}
+
+func (c Checker) responseHeaders() []string {
+	if c.conf.ResponseHeaders == nil {
+		return defaultResponseHeaders
+	}
+	return c.conf.ResponseHeaders
+}
+
+func (c Checker) bodyReadLimit() int64 {
+	if c.conf.BodyReadLimit == 0 {
+		return defaultBodyReadLimit
+	}
+	return c.conf.BodyReadLimit
+}
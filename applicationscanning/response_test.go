@@ -0,0 +1,71 @@
+package applicationscanning
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPage = "<!DOCTYPE html><html><head><title>\n  Example  Domain\n</title></head><body>Hello</body></html>"
+
+func newPageServer(t *testing.T) string {
+	return newLocalServer(t, pageHandler())
+}
+
+// pageHandler responds with testPage and the headers of a typical web application.
+func pageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+		w.Header().Add("X-Powered-By", "PHP/8.2")
+		w.Header().Add("X-Powered-By", "Laravel")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte(testPage))
+	})
+}
+
+func TestSendRequest_RecordsResponseMetadata(t *testing.T) {
+	serverURL := newPageServer(t)
+
+	_, result, err := NewChecker(CheckerConf{}).sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte(testPage))
+	response := result.Response
+	require.Equal(t, http.StatusTeapot, response.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", response.ContentType)
+	require.Equal(t, int64(len(testPage)), response.ContentLength)
+	require.Equal(t, "nginx/1.25.3", response.Server)
+	require.Equal(t, "Example Domain", response.Title)
+	require.Equal(t, hex.EncodeToString(hash[:]), response.BodyHash)
+	require.Equal(t, map[string]string{"Server": "nginx/1.25.3", "X-Powered-By": "PHP/8.2, Laravel"}, response.Headers)
+}
+
+func TestSendRequest_LimitsBodyRead(t *testing.T) {
+	serverURL := newPageServer(t)
+
+	checker := NewChecker(CheckerConf{BodyReadLimit: 10, ResponseHeaders: []string{"content-type"}})
+	_, result, err := checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte(testPage[:10]))
+	require.Equal(t, hex.EncodeToString(hash[:]), result.Response.BodyHash)
+	require.Equal(t, int64(10), result.Response.BodyBytesRead)
+	require.Empty(t, result.Response.Title)
+	require.Equal(t, map[string]string{"Content-Type": "text/html; charset=utf-8"}, result.Response.Headers)
+
+	_, result, err = NewChecker(CheckerConf{BodyReadLimit: -1}).sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Empty(t, result.Response.BodyHash)
+}
+
+func TestHTMLTitle(t *testing.T) {
+	require.Equal(t, "Example Domain", htmlTitle([]byte(testPage)))
+	require.Equal(t, "", htmlTitle([]byte(`{"title": "not HTML"}`)))
+	require.Equal(t, "", htmlTitle([]byte(strings.Repeat("<div>", 10))))
+}
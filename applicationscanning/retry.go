@@ -0,0 +1,209 @@
+package applicationscanning
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	endpointresolver "github.com/detectify/endpoint-resolver"
+)
+
+// RetryPolicy decides how the attempts of a check stage are retried when they fail. Its fields left zero are set to the
+// ones of the default policy of the stage, while negative values opt out of them.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one. When negative, attempts are only limited
+	// by MaxElapsedTime, or else a single attempt is made.
+	MaxAttempts int
+
+	// MaxElapsedTime stops retrying once exceeded. Negative means no limit.
+	MaxElapsedTime time.Duration
+
+	// InitialInterval is the delay before the first retry. Negative retries immediately.
+	InitialInterval time.Duration
+
+	// Multiplier grows the delay after each retry. Negative or one keeps the delay constant.
+	Multiplier float64
+
+	// MaxInterval caps the delay between retries. Negative means no cap.
+	MaxInterval time.Duration
+
+	// Jitter randomizes each delay by up to this fraction of it, e.g. 0.5 for a delay of 1s to be between 0.5s and 1.5s.
+	// Negative means no jitter.
+	Jitter float64
+
+	// Retryable decides whether an error is worth retrying. Defaults to endpointresolver.IsRetryable.
+	Retryable func(err error) bool
+}
+
+// RetryPolicies configures the RetryPolicy of each check stage. The fields of a RetryPolicy left zero are set to the ones
+// of the default policy of the stage, and negative ones opt out of them.
+type RetryPolicies struct {
+	// ExternalDNS defaults to an exponential backoff for up to 2 minutes. Each attempt queries every resolver.
+	ExternalDNS RetryPolicy
+
+	// NativeDNS defaults to a single attempt.
+	NativeDNS RetryPolicy
+
+	// Ports defaults to 3 attempts without delay. Each attempt dials the ports not found open yet.
+	Ports RetryPolicy
+
+	// HTTP defaults to a single attempt. Each attempt sends a single request.
+	HTTP RetryPolicy
+}
+
+var defaultRetryPolicies = map[endpointresolver.Stage]RetryPolicy{
+	endpointresolver.StageExternalDNS: {
+		MaxElapsedTime:  dnsRetriesMaxElapsedTime,
+		InitialInterval: backoff.DefaultInitialInterval,
+		Multiplier:      backoff.DefaultMultiplier,
+		MaxInterval:     backoff.DefaultMaxInterval,
+		Jitter:          backoff.DefaultRandomizationFactor,
+	},
+	endpointresolver.StageNativeDNS: {MaxAttempts: 1},
+	endpointresolver.StagePorts:     {MaxAttempts: portRetries},
+	endpointresolver.StageHTTP:      {MaxAttempts: 1},
+}
+
+// withDefaults returns a copy of the policy whose fields left zero are set to the ones of the default policy provided,
+// and whose negative fields are set to zero, which backOff takes as no limit, delay or jitter.
+func (p RetryPolicy) withDefaults(defaults RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	} else if p.MaxAttempts < 0 {
+		p.MaxAttempts = 0
+	}
+	if p.MaxElapsedTime == 0 {
+		p.MaxElapsedTime = defaults.MaxElapsedTime
+	} else if p.MaxElapsedTime < 0 {
+		p.MaxElapsedTime = 0
+	}
+	if p.InitialInterval == 0 {
+		p.InitialInterval = defaults.InitialInterval
+	} else if p.InitialInterval < 0 {
+		p.InitialInterval = 0
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaults.Multiplier
+	} else if p.Multiplier < 0 {
+		p.Multiplier = 0
+	}
+	if p.MaxInterval == 0 {
+		p.MaxInterval = defaults.MaxInterval
+	} else if p.MaxInterval < 0 {
+		p.MaxInterval = 0
+	}
+	if p.Jitter == 0 {
+		p.Jitter = defaults.Jitter
+	} else if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Retryable == nil {
+		p.Retryable = defaults.Retryable
+	}
+	return p
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return endpointresolver.IsRetryable(err)
+}
+
+func (p RetryPolicy) backOff(ctx context.Context) backoff.BackOff {
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     p.InitialInterval,
+		RandomizationFactor: p.Jitter,
+		Multiplier:          math.Max(p.Multiplier, 1),
+		MaxInterval:         p.MaxInterval,
+		MaxElapsedTime:      p.MaxElapsedTime,
+		Clock:               backoff.SystemClock,
+	}
+	if b.MaxInterval == 0 {
+		b.MaxInterval = time.Duration(math.MaxInt64)
+	}
+	b.Reset()
+
+	var withMaxAttempts backoff.BackOff = b
+	switch {
+	case p.MaxAttempts == 1, p.MaxAttempts == 0 && p.MaxElapsedTime == 0:
+		withMaxAttempts = &backoff.StopBackOff{}
+	case p.MaxAttempts > 1:
+		// a zero maximum number of retries means no maximum
+		withMaxAttempts = backoff.WithMaxRetries(b, uint64(p.MaxAttempts-1))
+	}
+	return backoff.WithContext(withMaxAttempts, ctx)
+}
+
+func (c Checker) retryPolicy(stage endpointresolver.Stage) RetryPolicy {
+	var policy RetryPolicy
+	switch stage {
+	case endpointresolver.StageExternalDNS:
+		policy = c.conf.RetryPolicies.ExternalDNS
+	case endpointresolver.StageNativeDNS:
+		policy = c.conf.RetryPolicies.NativeDNS
+	case endpointresolver.StagePorts:
+		policy = c.conf.RetryPolicies.Ports
+	case endpointresolver.StageHTTP:
+		policy = c.conf.RetryPolicies.HTTP
+	}
+	return policy.withDefaults(defaultRetryPolicies[stage])
+}
+
+// retry executes the operation provided according to the RetryPolicy of the stage, and returns the number of
+// attempts made along with the error of the last one.
+func (c Checker) retry(ctx context.Context, stage endpointresolver.Stage, operation func() error) (int, error) {
+	policy := c.retryPolicy(stage)
+
+	var attempts int
+	err := backoff.Retry(func() error {
+		attempts++
+		recordAttempt(ctx, stage)
+
+		err := operation()
+		retryable := err != nil && policy.retryable(err)
+		c.log().DebugContext(ctx, "attempt made", "stage", stage, "attempt", attempts, "error", err, "retryable", retryable)
+		if err != nil && !retryable {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, policy.backOff(ctx))
+	return attempts, err
+}
+
+type attemptsKey struct{}
+
+// attemptsRecorder counts the attempts made by each stage during a resolution.
+type attemptsRecorder struct {
+	mu       sync.Mutex
+	attempts map[endpointresolver.Stage]int
+}
+
+// withAttemptsRecorder returns a context in which the attempts made by checks are recorded by the recorder returned.
+func withAttemptsRecorder(ctx context.Context) (context.Context, *attemptsRecorder) {
+	recorder := &attemptsRecorder{attempts: make(map[endpointresolver.Stage]int)}
+	return context.WithValue(ctx, attemptsKey{}, recorder), recorder
+}
+
+func recordAttempt(ctx context.Context, stage endpointresolver.Stage) {
+	recorder, ok := ctx.Value(attemptsKey{}).(*attemptsRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.attempts[stage]++
+}
+
+func (r *attemptsRecorder) snapshot() map[endpointresolver.Stage]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts := make(map[endpointresolver.Stage]int, len(r.attempts))
+	for stage, count := range r.attempts {
+		attempts[stage] = count
+	}
+	return attempts
+}
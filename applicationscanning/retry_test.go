@@ -0,0 +1,150 @@
+package applicationscanning
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry_StopsAfterMaxAttempts(t *testing.T) {
+	checker := NewChecker(CheckerConf{RetryPolicies: RetryPolicies{HTTP: RetryPolicy{
+		MaxAttempts:     4,
+		InitialInterval: time.Millisecond,
+		Multiplier:      2,
+	}}})
+
+	ctx, attempts := withAttemptsRecorder(context.TODO())
+	n, err := checker.retry(ctx, endpointresolver.StageHTTP, func() error {
+		return errors.New("failed")
+	})
+	require.Error(t, err)
+	require.Equal(t, 4, n)
+	require.Equal(t, map[endpointresolver.Stage]int{endpointresolver.StageHTTP: 4}, attempts.snapshot())
+}
+
+func TestRetry_StopsOnNonRetryableError(t *testing.T) {
+	permanent := errors.New("permanent")
+	checker := NewChecker(CheckerConf{RetryPolicies: RetryPolicies{Ports: RetryPolicy{
+		MaxAttempts: 5,
+		Retryable:   func(err error) bool { return err != permanent },
+	}}})
+
+	var calls int
+	n, err := checker.retry(context.TODO(), endpointresolver.StagePorts, func() error {
+		calls++
+		if calls == 2 {
+			return permanent
+		}
+		return errors.New("temporary")
+	})
+	require.Equal(t, permanent, err)
+	require.Equal(t, 2, n)
+}
+
+func TestRetry_DefaultsPerStage(t *testing.T) {
+	for stage, expected := range map[endpointresolver.Stage]int{
+		endpointresolver.StageNativeDNS: 1,
+		endpointresolver.StagePorts:     portRetries,
+		endpointresolver.StageHTTP:      1,
+	} {
+		n, err := Checker{}.retry(context.TODO(), stage, func() error {
+			return errors.New("failed")
+		})
+		require.Error(t, err)
+		require.Equal(t, expected, n, stage)
+	}
+}
+
+func TestRetryPolicy_MergesWithStageDefaults(t *testing.T) {
+	retryable := func(error) bool { return true }
+	checker := NewChecker(CheckerConf{RetryPolicies: RetryPolicies{
+		ExternalDNS: RetryPolicy{Retryable: retryable},
+		Ports:       RetryPolicy{Jitter: 0.1},
+	}})
+
+	externalDNS := checker.retryPolicy(endpointresolver.StageExternalDNS)
+	require.NotNil(t, externalDNS.Retryable)
+	externalDNS.Retryable = nil
+	require.Equal(t, defaultRetryPolicies[endpointresolver.StageExternalDNS], externalDNS)
+
+	require.Equal(t, RetryPolicy{MaxAttempts: portRetries, Jitter: 0.1}, checker.retryPolicy(endpointresolver.StagePorts))
+}
+
+func TestRetryPolicy_OptsOutOfStageDefaults(t *testing.T) {
+	checker := NewChecker(CheckerConf{RetryPolicies: RetryPolicies{
+		ExternalDNS: RetryPolicy{MaxAttempts: 3, MaxElapsedTime: -1, InitialInterval: -1, Jitter: -1},
+		Ports:       RetryPolicy{MaxAttempts: -1, MaxElapsedTime: time.Second},
+	}})
+
+	require.Equal(t, RetryPolicy{
+		MaxAttempts: 3,
+		Multiplier:  backoff.DefaultMultiplier,
+		MaxInterval: backoff.DefaultMaxInterval,
+	}, checker.retryPolicy(endpointresolver.StageExternalDNS))
+	require.Equal(t, RetryPolicy{MaxElapsedTime: time.Second}, checker.retryPolicy(endpointresolver.StagePorts))
+
+	// without delay, the attempts of the external DNS stage are only limited by MaxAttempts
+	start := time.Now()
+	n, err := checker.retry(context.TODO(), endpointresolver.StageExternalDNS, func() error {
+		return errors.New("failed")
+	})
+	require.Error(t, err)
+	require.Equal(t, 3, n)
+	require.Less(t, time.Since(start), backoff.DefaultInitialInterval)
+}
+
+func TestRetry_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	n, err := Checker{}.retry(ctx, endpointresolver.StageExternalDNS, func() error {
+		cancel()
+		return errors.New("failed")
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestChecker_RetriesHTTP(t *testing.T) {
+	var requests int64
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) < 3 {
+			// drop the connection without a response, failing the request
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+	}))
+
+	checker := NewChecker(CheckerConf{RetryPolicies: RetryPolicies{HTTP: RetryPolicy{MaxAttempts: 3}}})
+	defer checker.CloseIdleConnections()
+
+	urls, _, err := checker.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: mozillaUserAgent}, "localhost", []int{port})
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	require.Equal(t, 3, urls[0].Attempts)
+}
+
+func TestChecker_ReportsFailureCause(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	_, err = Checker{}.Ports(context.TODO(), []string{"127.0.0.1"}, []int{port})
+	require.ErrorIs(t, err, endpointresolver.ErrNoOpenPort)
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	var resolveErr *endpointresolver.ResolveError
+	require.ErrorAs(t, err, &resolveErr)
+	require.Equal(t, endpointresolver.StagePorts, resolveErr.Stage)
+	require.Equal(t, "127.0.0.1", resolveErr.IP)
+	require.Equal(t, port, resolveErr.Port)
+}
@@ -0,0 +1,96 @@
+package applicationscanning
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+)
+
+// SlowResponse configures when a response is considered slow, raising a WarningHTTPTimeout warning.
+type SlowResponse struct {
+	// Timing is the timing compared to the threshold. Defaults to the total time taken, including any redirects.
+	Timing endpointresolver.Timing
+
+	// Threshold is the duration above which the timing is considered slow. Defaults to 4 seconds.
+	Threshold time.Duration
+}
+
+func (s SlowResponse) timing() endpointresolver.Timing {
+	if len(s.Timing) == 0 {
+		return endpointresolver.TimingTotal
+	}
+	return s.Timing
+}
+
+// hopTimer measures the timings of each request sent while following redirects, through httptrace hooks which may be
+// called concurrently.
+type hopTimer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timings      endpointresolver.Timings
+}
+
+func newHopTimer(start time.Time) *hopTimer {
+	return &hopTimer{start: start}
+}
+
+// clientTrace returns the hooks measuring the timings, calling the GotConn hook provided too.
+func (t *hopTimer) clientTrace(gotConn func(httptrace.GotConnInfo)) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: gotConn,
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.measure(&t.dnsStart, &t.timings.DNS)
+		},
+		ConnectStart: func(string, string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			t.measure(&t.connectStart, &t.timings.Connect)
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.measure(&t.tlsStart, &t.timings.TLSHandshake)
+		},
+		GotFirstResponseByte: func() {
+			t.measure(&t.start, &t.timings.TimeToFirstByte)
+		},
+	}
+}
+
+func (t *hopTimer) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+func (t *hopTimer) measure(since *time.Time, timing *time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if since.IsZero() {
+		return
+	}
+	*timing += time.Since(*since)
+}
+
+// next returns the timings of the current request, which ended at the time provided, and starts measuring the next one.
+func (t *hopTimer) next(end time.Time) endpointresolver.Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := t.timings
+	timings.Total = end.Sub(t.start)
+	t.timings = endpointresolver.Timings{}
+	t.start, t.dnsStart, t.connectStart, t.tlsStart = end, time.Time{}, time.Time{}, time.Time{}
+	return timings
+}
@@ -0,0 +1,62 @@
+package applicationscanning
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRequest_RecordsTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/next", http.StatusFound)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	checker := NewChecker(CheckerConf{})
+	defer checker.CloseIdleConnections()
+
+	_, result, err := checker.sendRequest(context.TODO(), server.URL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.RedirectChain))
+
+	// the connection is established for the first request and reused for the redirect
+	first, second := result.RedirectChain[0].Timings, result.RedirectChain[1].Timings
+	require.Greater(t, first.Connect, time.Duration(0))
+	require.Greater(t, first.TLSHandshake, time.Duration(0))
+	require.Equal(t, time.Duration(0), second.Connect)
+	require.Equal(t, time.Duration(0), second.TLSHandshake)
+	require.GreaterOrEqual(t, second.TimeToFirstByte, 20*time.Millisecond)
+	require.GreaterOrEqual(t, second.Total, second.TimeToFirstByte)
+
+	require.Equal(t, first.TLSHandshake, result.Timings.TLSHandshake)
+	require.Equal(t, first.TimeToFirstByte+second.TimeToFirstByte, result.Timings.TimeToFirstByte)
+	require.Equal(t, result.Duration, result.Timings.Total)
+}
+
+func TestHTTP_WarnsOnSlowTiming(t *testing.T) {
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+
+	checker := NewChecker(CheckerConf{SlowResponse: SlowResponse{
+		Timing:    endpointresolver.TimingTimeToFirstByte,
+		Threshold: 10 * time.Millisecond,
+	}})
+	defer checker.CloseIdleConnections()
+
+	urls, warnings, err := checker.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: mozillaUserAgent}, "localhost", []int{port})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(urls))
+	require.Equal(t, 1, len(warnings))
+	require.Equal(t, endpointresolver.WarningHTTPTimeout, warnings[0].Code)
+	require.Equal(t, urls[0].URL, warnings[0].URL)
+}
@@ -0,0 +1,80 @@
+package applicationscanning
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/detectify/endpoint-resolver/applicationscanning"
+
+// defaultTracer is the no-op tracer used without a TracerProvider.
+var defaultTracer = newTracer(nil)
+
+// newTracer returns the tracer of the provider provided, or one which records nothing when none is provided.
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = trace.NewNoopTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// endSpan records the error provided on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingTransport starts a span for each HTTP request sent through it, so that each redirect followed has one.
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+// RoundTrip implements http.RoundTripper
+func (t tracingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(r.Context(), "http.request",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.url", spanURL(r.URL)),
+		),
+	)
+	response, err := t.base.RoundTrip(r.WithContext(ctx))
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.status_code", response.StatusCode))
+	}
+	endSpan(span, err)
+	return response, err
+}
+
+// spanURL returns the URL provided as spans record it, without its user info and with the values of its query
+// parameters replaced by endpointresolver.Redacted.
+func spanURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	if redacted.RawQuery != "" {
+		params := strings.Split(redacted.RawQuery, "&")
+		for i, param := range params {
+			name, _, _ := strings.Cut(param, "=")
+			params[i] = name + "=" + endpointresolver.Redacted
+		}
+		redacted.RawQuery = strings.Join(params, "&")
+	}
+	return redacted.String()
+}
+
+func (c Checker) spanTracer() trace.Tracer {
+	if c.tracer == nil {
+		return defaultTracer
+	}
+	return c.tracer
+}
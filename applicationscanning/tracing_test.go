@@ -0,0 +1,76 @@
+package applicationscanning
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingTracer records the names and attributes of the spans started with it, which record nothing themselves.
+type recordingTracer struct {
+	mu         sync.Mutex
+	spans      []string
+	attributes []attribute.KeyValue
+}
+
+func (t *recordingTracer) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return t
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, name)
+	config := trace.NewSpanStartConfig(options...)
+	t.attributes = append(t.attributes, config.Attributes()...)
+	return trace.NewNoopTracerProvider().Tracer("").Start(ctx, name)
+}
+
+func TestSendRequest_StartsSpans(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.RedirectHandler("/app", http.StatusFound))
+	mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {})
+	serverURL := newLocalServer(t, mux)
+
+	tracer := &recordingTracer{}
+	checker := NewChecker(CheckerConf{TracerProvider: tracer})
+	_, _, err := checker.sendRequest(context.TODO(), serverURL+"/", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+	require.Equal(t, []string{"http.request", "net.dial", "http.request"}, tracer.spans)
+}
+
+func TestSendRequest_RecordsURLsWithoutSecrets(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.RedirectHandler("/app?session=secret", http.StatusFound))
+	mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {})
+	serverURL, _ := url.Parse(newLocalServer(t, mux))
+
+	tracer := &recordingTracer{}
+	checker := NewChecker(CheckerConf{TracerProvider: tracer})
+	_, _, err := checker.sendRequest(context.TODO(), "http://user:secret@"+serverURL.Host+"/?token=secret", request{userAgent: mozillaUserAgent})
+	require.NoError(t, err)
+
+	var urls []string
+	for _, attr := range tracer.attributes {
+		if attr.Key == "http.url" {
+			urls = append(urls, attr.Value.AsString())
+		}
+	}
+	require.Equal(t, []string{
+		"http://" + serverURL.Host + "/?token=REDACTED",
+		"http://" + serverURL.Host + "/app?session=REDACTED",
+	}, urls)
+}
+
+func TestPorts_StartsSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+	checker := NewChecker(CheckerConf{TracerProvider: tracer, RetryPolicies: RetryPolicies{Ports: RetryPolicy{MaxAttempts: 1}}})
+	_, _ = checker.Ports(context.TODO(), []string{"127.0.0.1"}, []int{1, 2})
+	require.Equal(t, []string{"net.dial", "net.dial"}, tracer.spans)
+}
@@ -0,0 +1,61 @@
+package applicationscanning
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 2
+	defaultIdleConnTimeout     = time.Second * 90
+	defaultDialTimeout         = time.Second * 10
+	defaultTLSHandshakeTimeout = time.Second * 10
+	dialKeepAlive              = time.Second * 30
+	maxDrainedBodySize         = 64 << 10
+)
+
+// defaultTransport dials through defaultDialer with the default timeouts, so that its connections are pooled across
+// every Checker using it.
+var defaultTransport = newTransport(CheckerConf{}, defaultDialer)
+
+// newTransport generates and returns the HTTP transport shared by all requests sent by a Checker.
+func newTransport(conf CheckerConf, dialer *dialer) *http.Transport {
+	return &http.Transport{
+		DialContext: dialer.DialContext,
+		// a custom TLS configuration disables HTTP/2 unless explicitly asked for
+		ForceAttemptHTTP2: true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Renegotiation:      tls.RenegotiateFreelyAsClient,
+			ClientSessionCache: tls.NewLRUClientSessionCache(conf.TLSSessionCacheSize),
+		},
+		MaxIdleConns:        intOrDefault(conf.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost: intOrDefault(conf.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		IdleConnTimeout:     durationOrDefault(conf.IdleConnTimeout, defaultIdleConnTimeout),
+		TLSHandshakeTimeout: durationOrDefault(conf.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+	}
+}
+
+// drainAndClose reads what is left of a response body, up to a limit, and closes it so that the underlying connection
+// can be reused.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainedBodySize))
+	_ = body.Close()
+}
+
+func intOrDefault(value, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func durationOrDefault(value, defaultValue time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,150 @@
+package applicationscanning
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// providerSignature describes how responses served by a WAF or CDN provider are recognized. A response matches when
+// any of the headers, cookies or body patterns matches.
+type providerSignature struct {
+	// provider is the name of the WAF or CDN
+	provider string
+
+	// headers maps canonical header names to a pattern their value must match, or nil when their presence is enough
+	headers map[string]*regexp.Regexp
+
+	// cookies are prefixes of the names of the cookies set
+	cookies []string
+
+	// bodies are patterns matching block and challenge pages
+	bodies []*regexp.Regexp
+}
+
+// providerSignatures are the signatures of common WAF and CDN providers.
+var providerSignatures = []providerSignature{
+	{
+		provider: "Akamai",
+		headers: map[string]*regexp.Regexp{
+			"Server":               regexp.MustCompile(`(?i)akamai`),
+			"X-Akamai-Transformed": nil,
+			"Akamai-Grn":           nil,
+		},
+		cookies: []string{"ak_bmsc", "_abck", "bm_sz"},
+		bodies:  []*regexp.Regexp{regexp.MustCompile(`(?i)errors\.edgesuite\.net`)},
+	},
+	{
+		provider: "AWS CloudFront",
+		headers: map[string]*regexp.Regexp{
+			"Server":      regexp.MustCompile(`(?i)^cloudfront$`),
+			"Via":         regexp.MustCompile(`(?i)\(cloudfront\)`),
+			"X-Amz-Cf-Id": nil,
+		},
+		bodies: []*regexp.Regexp{regexp.MustCompile(`(?i)generated by cloudfront`)},
+	},
+	{
+		provider: "AWS WAF",
+		headers:  map[string]*regexp.Regexp{"X-Amzn-Waf-Action": nil},
+		cookies:  []string{"aws-waf-token"},
+	},
+	{
+		provider: "Azure Front Door",
+		headers:  map[string]*regexp.Regexp{"X-Azure-Ref": nil},
+	},
+	{
+		provider: "Barracuda",
+		cookies:  []string{"barra_counter_session", "BNI__BARRACUDA_LB_COOKIE"},
+		bodies:   []*regexp.Regexp{regexp.MustCompile(`(?i)barracuda networks`)},
+	},
+	{
+		provider: "Cloudflare",
+		headers: map[string]*regexp.Regexp{
+			"Server": regexp.MustCompile(`(?i)^cloudflare`),
+			"Cf-Ray": nil,
+		},
+		cookies: []string{"__cf_bm", "__cfduid", "cf_clearance"},
+		bodies: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)attention required! \| cloudflare`),
+			regexp.MustCompile(`(?i)cf-error-details|cf-chl-`),
+		},
+	},
+	{
+		provider: "DataDome",
+		headers:  map[string]*regexp.Regexp{"X-Datadome": nil, "X-Datadome-Cid": nil},
+		cookies:  []string{"datadome"},
+	},
+	{
+		provider: "F5 BIG-IP",
+		headers:  map[string]*regexp.Regexp{"Server": regexp.MustCompile(`(?i)big-?ip`)},
+		cookies:  []string{"BIGipServer", "TS01"},
+		bodies:   []*regexp.Regexp{regexp.MustCompile(`(?i)the requested url was rejected\. please consult with your administrator`)},
+	},
+	{
+		provider: "Fastly",
+		headers: map[string]*regexp.Regexp{
+			"X-Fastly-Request-Id": nil,
+			"Fastly-Debug-Digest": nil,
+		},
+	},
+	{
+		provider: "Imperva Incapsula",
+		headers: map[string]*regexp.Regexp{
+			"X-Iinfo": nil,
+			"X-Cdn":   regexp.MustCompile(`(?i)incapsula|imperva`),
+		},
+		cookies: []string{"incap_ses_", "visid_incap_"},
+		bodies:  []*regexp.Regexp{regexp.MustCompile(`(?i)incapsula incident id`)},
+	},
+	{
+		provider: "ModSecurity",
+		headers:  map[string]*regexp.Regexp{"Server": regexp.MustCompile(`(?i)mod_security`)},
+		bodies:   []*regexp.Regexp{regexp.MustCompile(`(?i)generated by mod_security`)},
+	},
+	{
+		provider: "Sucuri",
+		headers: map[string]*regexp.Regexp{
+			"Server":      regexp.MustCompile(`(?i)sucuri`),
+			"X-Sucuri-Id": nil,
+		},
+		bodies: []*regexp.Regexp{regexp.MustCompile(`(?i)sucuri website firewall`)},
+	},
+}
+
+// detectProviders returns the names of the WAF and CDN providers whose signature the response provided matches, in
+// alphabetical order. The body provided is the beginning of the response body, if read.
+func detectProviders(response *http.Response, body []byte) []string {
+	cookies := response.Cookies()
+
+	var providers []string
+	for _, signature := range providerSignatures {
+		if signature.matches(response.Header, cookies, body) {
+			providers = append(providers, signature.provider)
+		}
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+func (s providerSignature) matches(header http.Header, cookies []*http.Cookie, body []byte) bool {
+	for name, pattern := range s.headers {
+		values := header.Values(name)
+		if len(values) > 0 && (pattern == nil || pattern.MatchString(strings.Join(values, ", "))) {
+			return true
+		}
+	}
+	for _, cookie := range cookies {
+		for _, prefix := range s.cookies {
+			if strings.HasPrefix(cookie.Name, prefix) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range s.bodies {
+		if pattern.Match(body) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package applicationscanning
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     string
+		expected []string
+	}{
+		{"no provider", http.Header{"Server": {"nginx"}}, "<html></html>", nil},
+		{"header presence", http.Header{"Cf-Ray": {"8a1b2c3d4e5f-ARN"}}, "", []string{"Cloudflare"}},
+		{"header value", http.Header{"Server": {"AkamaiGHost"}}, "", []string{"Akamai"}},
+		{"header value mismatch", http.Header{"X-Cdn": {"other"}}, "", nil},
+		{"cookie", http.Header{"Set-Cookie": {"incap_ses_123_456=abc; path=/"}}, "", []string{"Imperva Incapsula"}},
+		{"block page", nil, "<p>The requested URL was rejected. Please consult with your administrator.</p>", []string{"F5 BIG-IP"}},
+		{"several providers", http.Header{"Via": {"1.1 abc.cloudfront.net (CloudFront)"}, "X-Amzn-Waf-Action": {"captcha"}}, "", []string{"AWS CloudFront", "AWS WAF"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &http.Response{Header: tt.header}
+			if response.Header == nil {
+				response.Header = make(http.Header)
+			}
+			require.Equal(t, tt.expected, detectProviders(response, []byte(tt.body)))
+		})
+	}
+}
+
+func TestHTTP_NamesWAFBlockingUserAgent(t *testing.T) {
+	port := newLocalServerPort(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != defaultReferenceUserAgents[0] {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.Header().Set("Server", "cloudflare")
+	}))
+
+	_, _, err := Checker{}.HTTP(context.TODO(), endpointresolver.ResolveConf{UserAgent: "scanner"}, "localhost", []int{port})
+	require.ErrorIs(t, err, endpointresolver.ErrBlockedByWAF)
+	require.ErrorIs(t, err, endpointresolver.ErrBlockedByUserAgent)
+
+	var resolveErr *endpointresolver.ResolveError
+	require.True(t, errors.As(err, &resolveErr))
+	require.Equal(t, "Cloudflare", resolveErr.Provider)
+}
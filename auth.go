@@ -0,0 +1,50 @@
+package endpointresolver
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// Redacted replaces secrets wherever they would otherwise be exposed, such as in traces.
+const Redacted = "REDACTED"
+
+// Auth holds the credentials requests are authenticated with. Credentials left empty are not used.
+type Auth struct {
+	// Username and Password answer HTTP Basic and Digest challenges, only when sent by the URL requested, and not by
+	// the ones redirected to
+	Username string
+	Password string
+
+	// AllowBasicOverHTTP allows answering HTTP Basic challenges over plain HTTP, which sends the password in clear
+	AllowBasicOverHTTP bool
+
+	// BearerToken is sent in the Authorization header of each request, unless CustomHeaders already sets one
+	BearerToken string
+
+	// Cookies are sent with each request to the URL requested, and kept in a cookie jar along with the cookies set
+	// while following redirects
+	Cookies []*http.Cookie
+
+	// ClientCertificates are presented to servers requesting one during the TLS handshake, for mutual TLS
+	ClientCertificates []tls.Certificate
+}
+
+// Redacted returns a copy of the credentials with every secret replaced by Redacted, and without the client
+// certificates, so that it can be safely exposed.
+func (a Auth) Redacted() Auth {
+	if len(a.Password) > 0 {
+		a.Password = Redacted
+	}
+	if len(a.BearerToken) > 0 {
+		a.BearerToken = Redacted
+	}
+	if len(a.Cookies) > 0 {
+		cookies := make([]*http.Cookie, 0, len(a.Cookies))
+		for _, cookie := range a.Cookies {
+			cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: Redacted, Path: cookie.Path, Domain: cookie.Domain})
+		}
+		a.Cookies = cookies
+	}
+	a.ClientCertificates = nil
+	return a
+}
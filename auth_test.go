@@ -0,0 +1,29 @@
+package endpointresolver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth_Redacted(t *testing.T) {
+	auth := Auth{
+		Username:           "user",
+		Password:           "secret",
+		BearerToken:        "token",
+		Cookies:            []*http.Cookie{{Name: "session", Value: "secret", Path: "/"}},
+		ClientCertificates: []tls.Certificate{{}},
+	}
+
+	redacted := auth.Redacted()
+	require.Equal(t, Auth{
+		Username:    "user",
+		Password:    Redacted,
+		BearerToken: Redacted,
+		Cookies:     []*http.Cookie{{Name: "session", Value: Redacted, Path: "/"}},
+	}, redacted)
+	require.Equal(t, "secret", auth.Cookies[0].Value)
+	require.Equal(t, Auth{}, Auth{}.Redacted())
+}
@@ -1,6 +1,13 @@
 package endpointresolver
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
 
 var (
 	// ErrInvalidEndpoint is returned when the endpoint is neither an IP nor a domain
@@ -27,14 +34,157 @@ var (
 	// ErrBlockedByUserAgent is returned when the HTTP request was blocked due to the user agent string
 	ErrBlockedByUserAgent = errors.New("blocked by user-agent")
 
+	// ErrBlockedByWAF is returned when the HTTP request was blocked due to the user agent string by a WAF or CDN which
+	// was identified. It is a variant of ErrBlockedByUserAgent, which errors.Is matches too.
+	ErrBlockedByWAF = fmt.Errorf("blocked by WAF (%w)", ErrBlockedByUserAgent)
+
+	// ErrUnexpectedResponse is returned when no response satisfied the ExpectedResponse assertions configured
+	ErrUnexpectedResponse = errors.New("unexpected response")
+
+	// ErrInvalidExpectedResponse is returned when the ExpectedResponse assertions configured can't be evaluated, such as
+	// body assertions when bodies are not read
+	ErrInvalidExpectedResponse = errors.New("invalid expected response")
+
+	// ErrUnsupportedMethod is returned when the HTTP method requests should be sent with is not supported
+	ErrUnsupportedMethod = errors.New("unsupported HTTP method")
+
+	// ErrRequiredProtocolUnsupported is returned when none of the URLs found supports the protocol required
+	ErrRequiredProtocolUnsupported = errors.New("required protocol unsupported")
+
 	// ErrIPV6Unsupported is returned when the endpoint resolver needs to process an IPv6 address, which is
 	// currently unsupported
 	ErrIPV6Unsupported = errors.New("IPv6 addresses are not supported")
 
 	// WarnHTTPTimeout error is returned when the HTTP response occurred after a time threshold indicating that
 	// responses are slower than anticipated
+	//
+	// Deprecated: no longer returned, as warnings are reported in Result.Warnings with the WarningHTTPTimeout code.
 	WarnHTTPTimeout = errors.New("warning: HTTP timeout") //nolint:revive
 
 	// WarnRedirectedOutOfScope error is returned when the endpoint redirected out of scope, meaning another endpoint
+	//
+	// Deprecated: no longer returned, as warnings are reported in Result.Warnings with the WarningRedirectedOutOfScope
+	// code.
 	WarnRedirectedOutOfScope = errors.New("warning: redirection occurred outside scope") //nolint:revive
 )
+
+// ResolveError is returned when a check stage fails. It wraps one of the errors above, so that errors.Is still matches
+// it, along with the target involved and the underlying cause, which errors.Is and errors.As match too.
+type ResolveError struct {
+	// The stage which failed
+	Stage Stage
+
+	// One of the errors above, describing the failure
+	Err error
+
+	// The hostname involved, if any
+	Hostname string
+
+	// The IP address involved, if any
+	IP string
+
+	// The port involved, if any
+	Port int
+
+	// The URL involved, if any
+	URL string
+
+	// The WAF or CDN provider involved, if any
+	Provider string
+
+	// How the request was found blocked, for ErrBlockedByUserAgent and ErrBlockedByWAF
+	Evidence *BlockEvidence
+
+	// The underlying error which caused the failure, if known
+	Cause error
+}
+
+func (e *ResolveError) Error() string {
+	var target []string
+	if len(e.Hostname) > 0 {
+		target = append(target, "hostname "+e.Hostname)
+	}
+	if len(e.IP) > 0 {
+		target = append(target, "ip "+e.IP)
+	}
+	if e.Port > 0 {
+		target = append(target, "port "+strconv.Itoa(e.Port))
+	}
+	if len(e.URL) > 0 {
+		target = append(target, "url "+e.URL)
+	}
+	if len(e.Provider) > 0 {
+		target = append(target, "provider "+e.Provider)
+	}
+
+	msg := string(e.Stage) + ": " + e.Err.Error()
+	if len(target) > 0 {
+		msg += " (" + strings.Join(target, ", ") + ")"
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error describing the failure.
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the underlying cause matches the target.
+func (e *ResolveError) Is(target error) bool {
+	return e.Cause != nil && errors.Is(e.Cause, target)
+}
+
+// As finds the first error in the chain of the underlying cause matching the target.
+func (e *ResolveError) As(target interface{}) bool {
+	return e.Cause != nil && errors.As(e.Cause, target)
+}
+
+// IsRetryable reports whether the error provided is worth retrying, as opposed to being permanent. Cancelled contexts,
+// invalid input, DNS names which do not exist and blocked requests are permanent, as are errors reporting themselves
+// permanent through a Permanent method, while timeouts and other network errors are retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var resolveErr *ResolveError
+	if errors.As(err, &resolveErr) {
+		if !isRetryableSentinel(resolveErr.Err) {
+			return false
+		}
+		if resolveErr.Cause == nil {
+			return true
+		}
+		return IsRetryable(resolveErr.Cause)
+	}
+
+	var permanentErr permanent
+	if errors.As(err, &permanentErr) {
+		return !permanentErr.Permanent()
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	return isRetryableSentinel(err)
+}
+
+// permanent is implemented by errors which know whether they are permanent, such as the DNS response errors of
+// checkers.
+type permanent interface {
+	Permanent() bool
+}
+
+// isRetryableSentinel reports whether the error is not one of the errors above known to be permanent.
+func isRetryableSentinel(err error) bool {
+	switch err {
+	case ErrInvalidEndpoint, ErrInvalidEndpointPort, ErrIPV6Unsupported, ErrNoIPForEndpoint, ErrBlockedByUserAgent,
+		ErrBlockedByWAF, ErrRequiredProtocolUnsupported, ErrUnsupportedMethod, ErrInvalidExpectedResponse:
+		return false
+	default:
+		return true
+	}
+}
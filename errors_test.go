@@ -0,0 +1,59 @@
+package endpointresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveError_WrapsSentinelAndCause(t *testing.T) {
+	cause := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	err := error(&ResolveError{Stage: StagePorts, Err: ErrNoOpenPort, IP: "127.0.0.1", Port: 8080, Cause: cause})
+
+	require.ErrorIs(t, err, ErrNoOpenPort)
+	require.ErrorIs(t, err, cause)
+	var opErr *net.OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Equal(t, "ports: no open port (ip 127.0.0.1, port 8080): dial tcp: connection refused", err.Error())
+}
+
+// permanentError reports whether it is permanent.
+type permanentError bool
+
+func (e permanentError) Error() string {
+	return "permanent: " + strconv.FormatBool(bool(e))
+}
+
+func (e permanentError) Permanent() bool {
+	return bool(e)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"cancelled context", context.Canceled, false},
+		{"invalid input", ErrInvalidEndpoint, false},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection reset")}, true},
+		{"nonexisting DNS name", &net.DNSError{IsNotFound: true}, false},
+		{"DNS timeout", &net.DNSError{IsTimeout: true}, true},
+		{"retryable failure", &ResolveError{Stage: StageHTTP, Err: ErrNoHTTPConnection}, true},
+		{"permanent failure", &ResolveError{Stage: StageHTTP, Err: ErrBlockedByUserAgent}, false},
+		{"permanent cause", &ResolveError{Stage: StageNativeDNS, Err: ErrNativeDNSResolutionFailure, Cause: &net.DNSError{IsNotFound: true}}, false},
+		{"cancelled cause", &ResolveError{Stage: StagePorts, Err: ErrNoOpenPort, Cause: context.Canceled}, false},
+		{"permanent error", permanentError(true), false},
+		{"temporary error", permanentError(false), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, IsRetryable(tt.err))
+		})
+	}
+}
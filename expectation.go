@@ -0,0 +1,121 @@
+package endpointresolver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StatusRange is an inclusive range of HTTP status codes, e.g. 200 to 299.
+type StatusRange struct {
+	// The lowest status code in the range
+	Min int
+
+	// The highest status code in the range
+	Max int
+}
+
+// Contains reports whether the status code provided is in the range.
+func (r StatusRange) Contains(statusCode int) bool {
+	return statusCode >= r.Min && statusCode <= r.Max
+}
+
+func (r StatusRange) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprint(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// ExpectedResponse holds the assertions the response of a URL must satisfy for the URL to be considered reachable.
+// Assertions left empty are not evaluated, so that the zero value accepts any response.
+type ExpectedResponse struct {
+	// StatusCodes are the acceptable ranges of status codes
+	StatusCodes []StatusRange
+
+	// Headers maps names of headers the response must have to a pattern their value must match, or nil when their
+	// presence is enough
+	Headers map[string]*regexp.Regexp
+
+	// BodyContains is a string the body must contain, within the bytes read of it. Requests sent with HEAD, or whose
+	// bodies are not read, can't be checked for it.
+	BodyContains string
+
+	// BodyPattern is a pattern the body must match, within the bytes read of it. Requests sent with HEAD, or whose
+	// bodies are not read, can't be checked for it.
+	BodyPattern *regexp.Regexp
+
+	// MaxResponseTime is how long receiving the response, including redirects, may take at most
+	MaxResponseTime time.Duration
+}
+
+// Check evaluates the assertions against the response provided, of which body holds the bytes read, and returns an
+// *UnexpectedResponseError listing those which failed, if any.
+func (e ExpectedResponse) Check(statusCode int, header http.Header, body []byte, duration time.Duration) error {
+	var failed []string
+	if len(e.StatusCodes) > 0 && !e.statusCodeAccepted(statusCode) {
+		ranges := make([]string, 0, len(e.StatusCodes))
+		for _, r := range e.StatusCodes {
+			ranges = append(ranges, r.String())
+		}
+		failed = append(failed, fmt.Sprintf("status code %d not in %s", statusCode, strings.Join(ranges, ", ")))
+	}
+	names := make([]string, 0, len(e.Headers))
+	for name := range e.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pattern := e.Headers[name]
+		values := header.Values(name)
+		switch {
+		case len(values) == 0:
+			failed = append(failed, fmt.Sprintf("header %s missing", http.CanonicalHeaderKey(name)))
+		case pattern != nil && !pattern.MatchString(strings.Join(values, ", ")):
+			failed = append(failed, fmt.Sprintf("header %s does not match %q", http.CanonicalHeaderKey(name), pattern))
+		}
+	}
+	if len(e.BodyContains) > 0 && !bytes.Contains(body, []byte(e.BodyContains)) {
+		failed = append(failed, fmt.Sprintf("body does not contain %q", e.BodyContains))
+	}
+	if e.BodyPattern != nil && !e.BodyPattern.Match(body) {
+		failed = append(failed, fmt.Sprintf("body does not match %q", e.BodyPattern))
+	}
+	if e.MaxResponseTime > 0 && duration > e.MaxResponseTime {
+		failed = append(failed, fmt.Sprintf("response time %s exceeds %s", duration, e.MaxResponseTime))
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return &UnexpectedResponseError{Assertions: failed}
+}
+
+func (e ExpectedResponse) statusCodeAccepted(statusCode int) bool {
+	for _, r := range e.StatusCodes {
+		if r.Contains(statusCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnexpectedResponseError lists the assertions of an ExpectedResponse which a response failed. errors.Is matches it
+// with ErrUnexpectedResponse.
+type UnexpectedResponseError struct {
+	// The descriptions of the assertions which failed
+	Assertions []string
+}
+
+func (e *UnexpectedResponseError) Error() string {
+	return "failed assertions: " + strings.Join(e.Assertions, "; ")
+}
+
+// Is reports whether the target is ErrUnexpectedResponse.
+func (e *UnexpectedResponseError) Is(target error) bool {
+	return target == ErrUnexpectedResponse
+}
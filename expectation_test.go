@@ -0,0 +1,47 @@
+package endpointresolver
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedResponse_Check(t *testing.T) {
+	header := http.Header{"Server": []string{"nginx"}}
+	body := []byte("<title>Sign in</title>")
+
+	tests := []struct {
+		name     string
+		expected ExpectedResponse
+		failed   []string
+	}{
+		{"zero value accepts any response", ExpectedResponse{}, nil},
+		{"status code in range", ExpectedResponse{StatusCodes: []StatusRange{{500, 599}}}, nil},
+		{"status code out of ranges", ExpectedResponse{StatusCodes: []StatusRange{{200, 299}, {401, 401}}}, []string{"status code 503 not in 200-299, 401"}},
+		{"header present", ExpectedResponse{Headers: map[string]*regexp.Regexp{"server": nil}}, nil},
+		{"header missing", ExpectedResponse{Headers: map[string]*regexp.Regexp{"X-Frame-Options": nil}}, []string{"header X-Frame-Options missing"}},
+		{"header not matching", ExpectedResponse{Headers: map[string]*regexp.Regexp{"Server": regexp.MustCompile(`^apache`)}}, []string{`header Server does not match "^apache"`}},
+		{"body contains", ExpectedResponse{BodyContains: "Sign in"}, nil},
+		{"body does not contain", ExpectedResponse{BodyContains: "Maintenance"}, []string{`body does not contain "Maintenance"`}},
+		{"body does not match", ExpectedResponse{BodyPattern: regexp.MustCompile(`(?i)log ?in`)}, []string{`body does not match "(?i)log ?in"`}},
+		{"response time exceeded", ExpectedResponse{MaxResponseTime: time.Second}, []string{"response time 2s exceeds 1s"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.expected.Check(http.StatusServiceUnavailable, header, body, 2*time.Second)
+			if test.failed == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrUnexpectedResponse)
+			var unexpectedErr *UnexpectedResponseError
+			require.True(t, errors.As(err, &unexpectedErr))
+			require.Equal(t, test.failed, unexpectedErr.Assertions)
+		})
+	}
+}
@@ -1,17 +1,16 @@
 // Code generated by gowrap. DO NOT EDIT.
-// template: https://raw.githubusercontent.com/hexdigest/gowrap/6c8f05695fec23df85903a8da0af66ac414e2a63/templates/opentelemetry
+// template: templates/opentelemetry
 // gowrap: http://github.com/hexdigest/gowrap
 
 package opentelemetry
 
-//go:generate gowrap gen -p github.com/detectify/endpoint-resolver -i Checker -t https://raw.githubusercontent.com/hexdigest/gowrap/6c8f05695fec23df85903a8da0af66ac414e2a63/templates/opentelemetry -o checker.go -l ""
+//go:generate gowrap gen -p github.com/detectify/endpoint-resolver -i Checker -t templates/opentelemetry -o checker.go -l ""
 
 import (
 	"context"
 
 	endpointresolver "github.com/detectify/endpoint-resolver"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -20,13 +19,16 @@ type CheckerWithTracing struct {
 	endpointresolver.Checker
 	_instance      string
 	_spanDecorator func(span trace.Span, params, results map[string]interface{})
+	_redactor      Redactor
 }
 
-// NewCheckerWithTracing returns CheckerWithTracing
+// NewCheckerWithTracing returns CheckerWithTracing, decorating spans with DefaultSpanDecorator unless another span
+// decorator is provided
 func NewCheckerWithTracing(base endpointresolver.Checker, instance string, spanDecorator ...func(span trace.Span, params, results map[string]interface{})) CheckerWithTracing {
 	d := CheckerWithTracing{
-		Checker:   base,
-		_instance: instance,
+		Checker:        base,
+		_instance:      instance,
+		_spanDecorator: DefaultSpanDecorator,
 	}
 
 	if len(spanDecorator) > 0 && spanDecorator[0] != nil {
@@ -36,98 +38,69 @@ func NewCheckerWithTracing(base endpointresolver.Checker, instance string, spanD
 	return d
 }
 
-// CheckAllPorts implements endpointresolver.Checker
-func (_d CheckerWithTracing) Ports(ctx context.Context, ips []string, ports []int) (openPorts []int, err error) {
-	ctx, _span := otel.Tracer(_d._instance).Start(ctx, "endpointresolver.Checker.Ports")
-	defer func() {
-		if _d._spanDecorator != nil {
-			_d._spanDecorator(_span, map[string]interface{}{
-				"ctx":   ctx,
-				"ips":   ips,
-				"ports": ports}, map[string]interface{}{
-				"openPorts": openPorts,
-				"err":       err})
-		} else if err != nil {
-			_span.RecordError(err)
-			_span.SetAttributes(
-				attribute.String("event", "error"),
-				attribute.String("message", err.Error()),
-			)
-		}
-
-		_span.End()
-	}()
-	return _d.Checker.Ports(ctx, ips, ports)
+// WithRedactor returns a copy of CheckerWithTracing redacting the parameters and results passed to its span
+// decorator with the Redactor provided, instead of the default one
+func (_d CheckerWithTracing) WithRedactor(redactor Redactor) CheckerWithTracing {
+	_d._redactor = redactor
+	return _d
 }
 
-// DNSCheckWithExternalProvider implements endpointresolver.Checker
+// ExternalDNS implements endpointresolver.Checker
 func (_d CheckerWithTracing) ExternalDNS(ctx context.Context, hostname string, externalDNS []string) (err error) {
 	ctx, _span := otel.Tracer(_d._instance).Start(ctx, "endpointresolver.Checker.ExternalDNS")
 	defer func() {
-		if _d._spanDecorator != nil {
-			_d._spanDecorator(_span, map[string]interface{}{
-				"ctx":         ctx,
-				"hostname":    hostname,
-				"externalDNS": externalDNS}, map[string]interface{}{
-				"err": err})
-		} else if err != nil {
-			_span.RecordError(err)
-			_span.SetAttributes(
-				attribute.String("event", "error"),
-				attribute.String("message", err.Error()),
-			)
-		}
-
+		_d._spanDecorator(_span, _d._redactor.redact(map[string]interface{}{
+			"ctx":         ctx,
+			"hostname":    hostname,
+			"externalDNS": externalDNS}), _d._redactor.redact(map[string]interface{}{
+			"err": err}))
 		_span.End()
 	}()
 	return _d.Checker.ExternalDNS(ctx, hostname, externalDNS)
 }
 
-// HTTPCheck implements endpointresolver.Checker
-func (_d CheckerWithTracing) HTTP(ctx context.Context, userAgent string, hostname string, customHeaders map[string]string, openPorts []int) (sa1 []string, err error) {
+// HTTP implements endpointresolver.Checker
+func (_d CheckerWithTracing) HTTP(ctx context.Context, conf endpointresolver.ResolveConf, hostname string, openPorts []int) (ua1 []endpointresolver.URLResult, wa1 []endpointresolver.Warning, err error) {
 	ctx, _span := otel.Tracer(_d._instance).Start(ctx, "endpointresolver.Checker.HTTP")
 	defer func() {
-		if _d._spanDecorator != nil {
-			_d._spanDecorator(_span, map[string]interface{}{
-				"ctx":           ctx,
-				"userAgent":     userAgent,
-				"hostname":      hostname,
-				"customHeaders": customHeaders,
-				"openPorts":     openPorts}, map[string]interface{}{
-				"sa1": sa1,
-				"err": err})
-		} else if err != nil {
-			_span.RecordError(err)
-			_span.SetAttributes(
-				attribute.String("event", "error"),
-				attribute.String("message", err.Error()),
-			)
-		}
-
+		_d._spanDecorator(_span, _d._redactor.redact(map[string]interface{}{
+			"ctx":       ctx,
+			"conf":      conf,
+			"hostname":  hostname,
+			"openPorts": openPorts}), _d._redactor.redact(map[string]interface{}{
+			"ua1": ua1,
+			"wa1": wa1,
+			"err": err}))
 		_span.End()
 	}()
-	return _d.Checker.HTTP(ctx, userAgent, hostname, customHeaders, openPorts)
+	return _d.Checker.HTTP(ctx, conf, hostname, openPorts)
 }
 
-// NativeDNSCheck implements endpointresolver.Checker
+// NativeDNS implements endpointresolver.Checker
 func (_d CheckerWithTracing) NativeDNS(ctx context.Context, hostname string) (ips []string, err error) {
 	ctx, _span := otel.Tracer(_d._instance).Start(ctx, "endpointresolver.Checker.NativeDNS")
 	defer func() {
-		if _d._spanDecorator != nil {
-			_d._spanDecorator(_span, map[string]interface{}{
-				"ctx":      ctx,
-				"hostname": hostname}, map[string]interface{}{
-				"ips": ips,
-				"err": err})
-		} else if err != nil {
-			_span.RecordError(err)
-			_span.SetAttributes(
-				attribute.String("event", "error"),
-				attribute.String("message", err.Error()),
-			)
-		}
-
+		_d._spanDecorator(_span, _d._redactor.redact(map[string]interface{}{
+			"ctx":      ctx,
+			"hostname": hostname}), _d._redactor.redact(map[string]interface{}{
+			"ips": ips,
+			"err": err}))
 		_span.End()
 	}()
 	return _d.Checker.NativeDNS(ctx, hostname)
 }
+
+// Ports implements endpointresolver.Checker
+func (_d CheckerWithTracing) Ports(ctx context.Context, ips []string, ports []int) (openPorts []int, err error) {
+	ctx, _span := otel.Tracer(_d._instance).Start(ctx, "endpointresolver.Checker.Ports")
+	defer func() {
+		_d._spanDecorator(_span, _d._redactor.redact(map[string]interface{}{
+			"ctx":   ctx,
+			"ips":   ips,
+			"ports": ports}), _d._redactor.redact(map[string]interface{}{
+			"openPorts": openPorts,
+			"err":       err}))
+		_span.End()
+	}()
+	return _d.Checker.Ports(ctx, ips, ports)
+}
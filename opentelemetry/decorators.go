@@ -0,0 +1,147 @@
+package opentelemetry
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultSpanDecorator decorates the spans of ResolverWithTracing and CheckerWithTracing when no other decorator is
+// provided. Errors are recorded, warnings are added as events, and the attributes set describe the hostname and port
+// resolved, the number of IPs involved, the ports checked and found open, the number of URLs found and the codes of the
+// warnings raised, depending on the call.
+func DefaultSpanDecorator(span trace.Span, params, results map[string]interface{}) {
+	if err, ok := results["err"].(error); ok && err != nil {
+		recordError(span, err)
+	}
+
+	if hostname, ok := params["hostname"].(string); ok {
+		span.SetAttributes(attribute.String("net.peer.name", hostname))
+	} else if conf, ok := params["conf"].(endpointresolver.ResolveConf); ok {
+		hostname, port, _ := strings.Cut(conf.Endpoint, ":")
+		span.SetAttributes(attribute.String("net.peer.name", hostname))
+		if port, err := strconv.Atoi(port); err == nil {
+			span.SetAttributes(attribute.Int("server.port", port))
+		}
+	}
+	if externalDNS, ok := params["externalDNS"].([]string); ok {
+		span.SetAttributes(attribute.Int("dns.resolver.count", len(externalDNS)))
+	}
+	if ips, ok := params["ips"].([]string); ok {
+		span.SetAttributes(attribute.Int("ip.count", len(ips)))
+	}
+	if ips, ok := results["ips"].([]string); ok {
+		span.SetAttributes(attribute.Int("ip.count", len(ips)))
+	}
+	if ports, ok := params["ports"].([]int); ok {
+		span.SetAttributes(attribute.IntSlice("ports", ports))
+	}
+	for _, openPorts := range []interface{}{params["openPorts"], results["openPorts"]} {
+		if openPorts, ok := openPorts.([]int); ok {
+			span.SetAttributes(attribute.IntSlice("open_ports", openPorts))
+		}
+	}
+
+	var urls []endpointresolver.URLResult
+	var warnings []endpointresolver.Warning
+	_, isHTTP := results["ua1"]
+	result, isResolve := results["result"].(endpointresolver.Result)
+	switch {
+	case isHTTP:
+		urls, _ = results["ua1"].([]endpointresolver.URLResult)
+		warnings, _ = results["wa1"].([]endpointresolver.Warning)
+	case isResolve:
+		urls, warnings = result.URLs, result.Warnings
+	}
+	if isHTTP || isResolve {
+		span.SetAttributes(
+			attribute.Int("url.count", len(urls)),
+			attribute.StringSlice("warning.codes", warningCodes(warnings)),
+		)
+	}
+	for _, warning := range warnings {
+		span.AddEvent("warning", trace.WithAttributes(
+			attribute.String("code", string(warning.Code)),
+			attribute.String("message", warning.Message),
+			attribute.String("url", warning.URL),
+		))
+	}
+}
+
+// CheckerSpanDecorator decorates the spans of CheckerWithTracing. Along with the attributes of DefaultSpanDecorator, the
+// URLs found by the HTTP check are added as attributes along with their timings.
+func CheckerSpanDecorator(span trace.Span, params, results map[string]interface{}) {
+	DefaultSpanDecorator(span, params, results)
+	if urls, ok := results["ua1"].([]endpointresolver.URLResult); ok {
+		span.SetAttributes(TimingAttributes(urls)...)
+	}
+}
+
+// ResolverSpanDecorator decorates the spans of ResolverWithTracing with attributes which are safe to expose, leaving out
+// the user agent, custom headers and credentials. Along with the attributes of DefaultSpanDecorator, the endpoint, ports,
+// method and paths requested are added, along with the URLs found and their timings.
+func ResolverSpanDecorator(span trace.Span, params, results map[string]interface{}) {
+	DefaultSpanDecorator(span, params, results)
+	if conf, ok := params["conf"].(endpointresolver.ResolveConf); ok {
+		span.SetAttributes(
+			attribute.String("endpoint", conf.Endpoint),
+			attribute.IntSlice("ports", conf.Ports),
+			attribute.String("http.method", conf.Method),
+			attribute.StringSlice("http.paths", conf.Paths),
+		)
+	}
+	if result, ok := results["result"].(endpointresolver.Result); ok {
+		span.SetAttributes(TimingAttributes(result.URLs)...)
+	}
+}
+
+// TimingAttributes returns span attributes listing the URLs provided along with their timings, in milliseconds. Each
+// attribute is a list holding a value per URL, in the same order.
+func TimingAttributes(urls []endpointresolver.URLResult) []attribute.KeyValue {
+	timings := []endpointresolver.Timing{
+		endpointresolver.TimingDNS,
+		endpointresolver.TimingConnect,
+		endpointresolver.TimingTLSHandshake,
+		endpointresolver.TimingTimeToFirstByte,
+		endpointresolver.TimingTotal,
+	}
+
+	urlValues := make([]string, 0, len(urls))
+	timingValues := make([][]float64, len(timings))
+	for _, u := range urls {
+		urlValues = append(urlValues, u.URL)
+		for i, timing := range timings {
+			timingValues[i] = append(timingValues[i], milliseconds(u.Timings.Get(timing)))
+		}
+	}
+
+	attributes := []attribute.KeyValue{attribute.StringSlice("http.urls", urlValues)}
+	for i, timing := range timings {
+		attributes = append(attributes, attribute.Float64Slice("http.timings."+string(timing)+"_ms", timingValues[i]))
+	}
+	return attributes
+}
+
+func warningCodes(warnings []endpointresolver.Warning) []string {
+	codes := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		codes = append(codes, string(warning.Code))
+	}
+	return codes
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetAttributes(
+		attribute.String("event", "error"),
+		attribute.String("message", err.Error()),
+	)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
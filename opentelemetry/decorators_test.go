@@ -0,0 +1,76 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingSpan records the attributes, events and errors added to it.
+type recordingSpan struct {
+	trace.Span
+	attributes map[attribute.Key]attribute.Value
+	events     []string
+	errors     []error
+}
+
+func newRecordingSpan() *recordingSpan {
+	return &recordingSpan{
+		Span:       trace.SpanFromContext(context.Background()),
+		attributes: make(map[attribute.Key]attribute.Value),
+	}
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		s.attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) AddEvent(name string, _ ...trace.EventOption) {
+	s.events = append(s.events, name)
+}
+
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.errors = append(s.errors, err)
+}
+
+func TestDefaultSpanDecorator_HTTP(t *testing.T) {
+	span := newRecordingSpan()
+	DefaultSpanDecorator(span, map[string]interface{}{
+		"hostname":  "example.com",
+		"openPorts": []int{80, 443},
+	}, map[string]interface{}{
+		"ua1": []endpointresolver.URLResult{{URL: "https://example.com/"}},
+		"wa1": []endpointresolver.Warning{{Code: endpointresolver.WarningHTTPTimeout, URL: "https://example.com/"}},
+		"err": nil,
+	})
+
+	require.Equal(t, "example.com", span.attributes["net.peer.name"].AsString())
+	require.Equal(t, []int64{80, 443}, span.attributes["open_ports"].AsInt64Slice())
+	require.Equal(t, int64(1), span.attributes["url.count"].AsInt64())
+	require.Equal(t, []string{"http_timeout"}, span.attributes["warning.codes"].AsStringSlice())
+	require.Equal(t, []string{"warning"}, span.events)
+	require.Empty(t, span.errors)
+}
+
+func TestDefaultSpanDecorator_Resolve(t *testing.T) {
+	err := errors.New("failed")
+	span := newRecordingSpan()
+	DefaultSpanDecorator(span, map[string]interface{}{
+		"conf": endpointresolver.ResolveConf{Endpoint: "example.com:8443"},
+	}, map[string]interface{}{
+		"result": endpointresolver.Result{},
+		"err":    err,
+	})
+
+	require.Equal(t, "example.com", span.attributes["net.peer.name"].AsString())
+	require.Equal(t, int64(8443), span.attributes["server.port"].AsInt64())
+	require.Equal(t, int64(0), span.attributes["url.count"].AsInt64())
+	require.Equal(t, []error{err}, span.errors)
+}
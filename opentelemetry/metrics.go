@@ -0,0 +1,183 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+)
+
+const meterName = "github.com/detectify/endpoint-resolver/opentelemetry"
+
+// stageResolve is the stage attribute of the measurements of whole resolutions.
+const stageResolve = "resolve"
+
+// outcomes name the errors reported in the outcome attribute of measurements, in the order they are matched.
+var outcomes = []struct {
+	err  error
+	name string
+}{
+	{endpointresolver.ErrInvalidEndpoint, "invalid_endpoint"},
+	{endpointresolver.ErrInvalidEndpointPort, "invalid_endpoint_port"},
+	{endpointresolver.ErrIPV6Unsupported, "ipv6_unsupported"},
+	{endpointresolver.ErrThirdPartyDNSResolutionFailure, "third_party_dns_resolution_failure"},
+	{endpointresolver.ErrNativeDNSResolutionFailure, "native_dns_resolution_failure"},
+	{endpointresolver.ErrNoIPForEndpoint, "no_ip_for_endpoint"},
+	{endpointresolver.ErrNoOpenPort, "no_open_port"},
+	{endpointresolver.ErrNoHTTPConnection, "no_http_connection"},
+	{endpointresolver.ErrBlockedByWAF, "blocked_by_waf"},
+	{endpointresolver.ErrBlockedByUserAgent, "blocked_by_user_agent"},
+	{endpointresolver.ErrRequiredProtocolUnsupported, "required_protocol_unsupported"},
+	{endpointresolver.ErrUnexpectedResponse, "unexpected_response"},
+	{endpointresolver.ErrInvalidExpectedResponse, "invalid_expected_response"},
+	{endpointresolver.ErrUnsupportedMethod, "unsupported_method"},
+	{context.Canceled, "canceled"},
+	{context.DeadlineExceeded, "deadline_exceeded"},
+}
+
+// outcome returns the name of the outcome of a call which returned the error provided.
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	for _, o := range outcomes {
+		if errors.Is(err, o.err) {
+			return o.name
+		}
+	}
+	return "error"
+}
+
+// instruments records the measurements of ResolverWithMetrics and CheckerWithMetrics.
+type instruments struct {
+	duration instrument.Float64Histogram
+	outcomes instrument.Int64Counter
+	warnings instrument.Int64Counter
+	inFlight instrument.Int64UpDownCounter
+}
+
+func newInstruments(provider metric.MeterProvider) (*instruments, error) {
+	meter := provider.Meter(meterName)
+
+	var i instruments
+	var err error
+	if i.duration, err = meter.Float64Histogram("endpointresolver.duration",
+		instrument.WithUnit("ms"),
+		instrument.WithDescription("Duration of resolutions and of each check stage")); err != nil {
+		return nil, err
+	}
+	if i.outcomes, err = meter.Int64Counter("endpointresolver.outcomes",
+		instrument.WithDescription("Outcomes of resolutions and of each check stage, by error")); err != nil {
+		return nil, err
+	}
+	if i.warnings, err = meter.Int64Counter("endpointresolver.warnings",
+		instrument.WithDescription("Warnings raised by resolutions and by the HTTP check stage, by code")); err != nil {
+		return nil, err
+	}
+	if i.inFlight, err = meter.Int64UpDownCounter("endpointresolver.resolutions.in_flight",
+		instrument.WithDescription("Resolutions in progress")); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// record records the duration and outcome of a call of the stage provided, which started at the time provided.
+func (i *instruments) record(ctx context.Context, stage string, start time.Time, err error) {
+	attributes := []attribute.KeyValue{attribute.String("stage", stage), attribute.String("outcome", outcome(err))}
+	i.duration.Record(ctx, milliseconds(time.Since(start)), attributes...)
+	i.outcomes.Add(ctx, 1, attributes...)
+}
+
+// recordWarnings records the warnings raised by a call of the stage provided.
+func (i *instruments) recordWarnings(ctx context.Context, stage string, warnings []endpointresolver.Warning) {
+	for _, warning := range warnings {
+		i.warnings.Add(ctx, 1, attribute.String("stage", stage), attribute.String("code", string(warning.Code)))
+	}
+}
+
+// ResolverWithMetrics implements endpointresolver.Resolver interface instrumented with OpenTelemetry metrics: the
+// duration and outcome of each resolution, the warnings raised, and the number of resolutions in progress.
+type ResolverWithMetrics struct {
+	endpointresolver.Resolver
+	instruments *instruments
+}
+
+// NewResolverWithMetrics returns ResolverWithMetrics, recording measurements with the meters of the provider provided,
+// or an error when the instruments could not be created.
+func NewResolverWithMetrics(base endpointresolver.Resolver, provider metric.MeterProvider) (ResolverWithMetrics, error) {
+	instruments, err := newInstruments(provider)
+	if err != nil {
+		return ResolverWithMetrics{}, err
+	}
+	return ResolverWithMetrics{Resolver: base, instruments: instruments}, nil
+}
+
+// Resolve implements endpointresolver.Resolver
+func (d ResolverWithMetrics) Resolve(ctx context.Context, conf endpointresolver.ResolveConf) (result endpointresolver.Result, err error) {
+	d.instruments.inFlight.Add(ctx, 1)
+	start := time.Now()
+	defer func() {
+		d.instruments.inFlight.Add(ctx, -1)
+		d.instruments.record(ctx, stageResolve, start, err)
+		d.instruments.recordWarnings(ctx, stageResolve, result.Warnings)
+	}()
+	return d.Resolver.Resolve(ctx, conf)
+}
+
+// CheckerWithMetrics implements endpointresolver.Checker interface instrumented with OpenTelemetry metrics: the
+// duration and outcome of each check stage, and the warnings raised by the HTTP check.
+type CheckerWithMetrics struct {
+	endpointresolver.Checker
+	instruments *instruments
+}
+
+// NewCheckerWithMetrics returns CheckerWithMetrics, recording measurements with the meters of the provider provided, or
+// an error when the instruments could not be created.
+func NewCheckerWithMetrics(base endpointresolver.Checker, provider metric.MeterProvider) (CheckerWithMetrics, error) {
+	instruments, err := newInstruments(provider)
+	if err != nil {
+		return CheckerWithMetrics{}, err
+	}
+	return CheckerWithMetrics{Checker: base, instruments: instruments}, nil
+}
+
+// ExternalDNS implements endpointresolver.Checker
+func (d CheckerWithMetrics) ExternalDNS(ctx context.Context, hostname string, externalDNS []string) (err error) {
+	start := time.Now()
+	defer func() {
+		d.instruments.record(ctx, string(endpointresolver.StageExternalDNS), start, err)
+	}()
+	return d.Checker.ExternalDNS(ctx, hostname, externalDNS)
+}
+
+// NativeDNS implements endpointresolver.Checker
+func (d CheckerWithMetrics) NativeDNS(ctx context.Context, hostname string) (ips []string, err error) {
+	start := time.Now()
+	defer func() {
+		d.instruments.record(ctx, string(endpointresolver.StageNativeDNS), start, err)
+	}()
+	return d.Checker.NativeDNS(ctx, hostname)
+}
+
+// Ports implements endpointresolver.Checker
+func (d CheckerWithMetrics) Ports(ctx context.Context, ips []string, ports []int) (openPorts []int, err error) {
+	start := time.Now()
+	defer func() {
+		d.instruments.record(ctx, string(endpointresolver.StagePorts), start, err)
+	}()
+	return d.Checker.Ports(ctx, ips, ports)
+}
+
+// HTTP implements endpointresolver.Checker
+func (d CheckerWithMetrics) HTTP(ctx context.Context, conf endpointresolver.ResolveConf, hostname string, openPorts []int) (urls []endpointresolver.URLResult, warnings []endpointresolver.Warning, err error) {
+	start := time.Now()
+	defer func() {
+		d.instruments.record(ctx, string(endpointresolver.StageHTTP), start, err)
+		d.instruments.recordWarnings(ctx, string(endpointresolver.StageHTTP), warnings)
+	}()
+	return d.Checker.HTTP(ctx, conf, hostname, openPorts)
+}
@@ -0,0 +1,202 @@
+package opentelemetry
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+)
+
+// recordingMeter records the values added to its counters, and the number of values recorded by its histograms, by
+// instrument name and attributes, and wraps a no-op meter otherwise.
+type recordingMeter struct {
+	metric.Meter
+	mu      sync.Mutex
+	values  map[string]float64
+	records map[string]int
+}
+
+func newRecordingMeter() *recordingMeter {
+	return &recordingMeter{
+		Meter:   metric.NewNoopMeterProvider().Meter(""),
+		values:  make(map[string]float64),
+		records: make(map[string]int),
+	}
+}
+
+// recordingMeterProvider provides its recording meter whatever the name.
+type recordingMeterProvider struct {
+	meter *recordingMeter
+}
+
+func (p recordingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return p.meter
+}
+
+func (m *recordingMeter) add(name string, value float64, attrs []attribute.KeyValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[recordingKey(name, attrs)] += value
+}
+
+func (m *recordingMeter) record(name string, value float64, attrs []attribute.KeyValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value >= 0 {
+		m.records[recordingKey(name, attrs)]++
+	}
+}
+
+// recordingKey returns the key the values recorded by the instrument named, with the attributes provided, are kept by.
+func recordingKey(name string, attrs []attribute.KeyValue) string {
+	key := name
+	for _, attr := range attrs {
+		key += " " + string(attr.Key) + "=" + attr.Value.Emit()
+	}
+	return key
+}
+
+type recordingInt64Counter struct {
+	instrument.Int64Counter
+	meter *recordingMeter
+	name  string
+}
+
+func (c recordingInt64Counter) Add(_ context.Context, incr int64, attrs ...attribute.KeyValue) {
+	c.meter.add(c.name, float64(incr), attrs)
+}
+
+type recordingInt64UpDownCounter struct {
+	instrument.Int64UpDownCounter
+	meter *recordingMeter
+	name  string
+}
+
+func (c recordingInt64UpDownCounter) Add(_ context.Context, incr int64, attrs ...attribute.KeyValue) {
+	c.meter.add(c.name, float64(incr), attrs)
+}
+
+type recordingFloat64Histogram struct {
+	instrument.Float64Histogram
+	meter *recordingMeter
+	name  string
+}
+
+func (h recordingFloat64Histogram) Record(_ context.Context, value float64, attrs ...attribute.KeyValue) {
+	h.meter.record(h.name, value, attrs)
+}
+
+func (m *recordingMeter) Int64Counter(name string, options ...instrument.Int64Option) (instrument.Int64Counter, error) {
+	counter, err := m.Meter.Int64Counter(name, options...)
+	return recordingInt64Counter{Int64Counter: counter, meter: m, name: name}, err
+}
+
+func (m *recordingMeter) Int64UpDownCounter(name string, options ...instrument.Int64Option) (instrument.Int64UpDownCounter, error) {
+	counter, err := m.Meter.Int64UpDownCounter(name, options...)
+	return recordingInt64UpDownCounter{Int64UpDownCounter: counter, meter: m, name: name}, err
+}
+
+func (m *recordingMeter) Float64Histogram(name string, options ...instrument.Float64Option) (instrument.Float64Histogram, error) {
+	histogram, err := m.Meter.Float64Histogram(name, options...)
+	return recordingFloat64Histogram{Float64Histogram: histogram, meter: m, name: name}, err
+}
+
+type resolverFunc func(ctx context.Context, conf endpointresolver.ResolveConf) (endpointresolver.Result, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, conf endpointresolver.ResolveConf) (endpointresolver.Result, error) {
+	return f(ctx, conf)
+}
+
+func TestResolverWithMetrics(t *testing.T) {
+	meter := newRecordingMeter()
+	var inFlight float64
+	resolver, err := NewResolverWithMetrics(resolverFunc(func(ctx context.Context, conf endpointresolver.ResolveConf) (endpointresolver.Result, error) {
+		inFlight = meter.values["endpointresolver.resolutions.in_flight"]
+		if conf.Endpoint == "closed.example.com" {
+			return endpointresolver.Result{}, &endpointresolver.ResolveError{Stage: endpointresolver.StagePorts, Err: endpointresolver.ErrNoOpenPort}
+		}
+		return endpointresolver.Result{Warnings: []endpointresolver.Warning{{Code: endpointresolver.WarningHTTPTimeout}}}, nil
+	}), recordingMeterProvider{meter: meter})
+	require.NoError(t, err)
+
+	_, _ = resolver.Resolve(context.TODO(), endpointresolver.ResolveConf{Endpoint: "example.com"})
+	_, _ = resolver.Resolve(context.TODO(), endpointresolver.ResolveConf{Endpoint: "closed.example.com"})
+
+	require.Equal(t, float64(1), inFlight)
+	require.Equal(t, map[string]float64{
+		"endpointresolver.resolutions.in_flight":                       0,
+		"endpointresolver.outcomes stage=resolve outcome=ok":           1,
+		"endpointresolver.outcomes stage=resolve outcome=no_open_port": 1,
+		"endpointresolver.warnings stage=resolve code=http_timeout":    1,
+	}, meter.values)
+	require.Equal(t, map[string]int{
+		"endpointresolver.duration stage=resolve outcome=ok":           1,
+		"endpointresolver.duration stage=resolve outcome=no_open_port": 1,
+	}, meter.records)
+}
+
+// stagesChecker returns the results it holds from every check stage, along with its error.
+type stagesChecker struct {
+	ips      []string
+	ports    []int
+	urls     []endpointresolver.URLResult
+	warnings []endpointresolver.Warning
+	err      error
+}
+
+func (c stagesChecker) ExternalDNS(context.Context, string, []string) error {
+	return c.err
+}
+
+func (c stagesChecker) NativeDNS(context.Context, string) ([]string, error) {
+	return c.ips, c.err
+}
+
+func (c stagesChecker) Ports(context.Context, []string, []int) ([]int, error) {
+	return c.ports, c.err
+}
+
+func (c stagesChecker) HTTP(context.Context, endpointresolver.ResolveConf, string, []int) ([]endpointresolver.URLResult, []endpointresolver.Warning, error) {
+	return c.urls, c.warnings, c.err
+}
+
+func TestCheckerWithMetrics(t *testing.T) {
+	meter := newRecordingMeter()
+	checker, err := NewCheckerWithMetrics(stagesChecker{
+		ips:      []string{"127.0.0.1"},
+		ports:    []int{443},
+		warnings: []endpointresolver.Warning{{Code: endpointresolver.WarningHTTPTimeout}},
+	}, recordingMeterProvider{meter: meter})
+	require.NoError(t, err)
+	failingChecker, err := NewCheckerWithMetrics(stagesChecker{
+		err: &endpointresolver.ResolveError{Stage: endpointresolver.StagePorts, Err: endpointresolver.ErrNoOpenPort},
+	}, recordingMeterProvider{meter: meter})
+	require.NoError(t, err)
+
+	_ = checker.ExternalDNS(context.TODO(), "example.com", []string{"1.1.1.1:53"})
+	_, _ = checker.NativeDNS(context.TODO(), "example.com")
+	_, _ = checker.Ports(context.TODO(), []string{"127.0.0.1"}, []int{443})
+	_, _ = failingChecker.Ports(context.TODO(), []string{"127.0.0.1"}, []int{80})
+	_, _, _ = checker.HTTP(context.TODO(), endpointresolver.ResolveConf{}, "example.com", []int{443})
+
+	require.Equal(t, map[string]float64{
+		"endpointresolver.outcomes stage=external_dns outcome=ok":    1,
+		"endpointresolver.outcomes stage=native_dns outcome=ok":      1,
+		"endpointresolver.outcomes stage=ports outcome=ok":           1,
+		"endpointresolver.outcomes stage=ports outcome=no_open_port": 1,
+		"endpointresolver.outcomes stage=http outcome=ok":            1,
+		"endpointresolver.warnings stage=http code=http_timeout":     1,
+	}, meter.values)
+	require.Equal(t, map[string]int{
+		"endpointresolver.duration stage=external_dns outcome=ok":    1,
+		"endpointresolver.duration stage=native_dns outcome=ok":      1,
+		"endpointresolver.duration stage=ports outcome=ok":           1,
+		"endpointresolver.duration stage=ports outcome=no_open_port": 1,
+		"endpointresolver.duration stage=http outcome=ok":            1,
+	}, meter.records)
+}
@@ -0,0 +1,223 @@
+package opentelemetry
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	endpointresolver "github.com/detectify/endpoint-resolver"
+)
+
+// defaultRedactedHeaders are the names of the headers whose values are redacted when none are configured.
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// urlPattern matches the URLs, and paths with queries, found in error messages and warnings.
+var urlPattern = regexp.MustCompile(`[^\s"'<>()]*(?:://|\?)[^\s"'<>()]*`)
+
+// defaultRedactedPatterns match the names of further headers whose values are redacted when none are configured.
+var defaultRedactedPatterns = []*regexp.Regexp{regexp.MustCompile(`(?i)token|secret|password|api-?key|session`)}
+
+// Redactor replaces the values of sensitive headers, of URL query parameters and passwords, including those of the URLs
+// mentioned by errors and warnings, and the credentials of the resolving config, with endpointresolver.Redacted before
+// they reach span decorators. Request bodies are dropped. The zero value redacts the default headers.
+type Redactor struct {
+	// Headers are the names of the headers whose values are redacted, matched case-insensitively. Defaults to
+	// Authorization, Proxy-Authorization, Cookie, Set-Cookie and X-Api-Key.
+	Headers []string
+
+	// Patterns match the names of further headers whose values are redacted. Defaults to names mentioning tokens,
+	// secrets, passwords, API keys or sessions.
+	Patterns []*regexp.Regexp
+}
+
+// Redacts reports whether the value of the header named is redacted.
+func (r Redactor) Redacts(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, header := range r.headers() {
+		if http.CanonicalHeaderKey(header) == name {
+			return true
+		}
+	}
+	for _, pattern := range r.patterns() {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders returns a copy of the headers provided whose sensitive values are redacted.
+func (r Redactor) RedactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if r.Redacts(name) {
+			value = endpointresolver.Redacted
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// RedactURL returns the URL provided with the values of its query parameters, and its password if any, redacted.
+func (r Redactor) RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// keep what precedes the query only, as the URL can't be told apart otherwise
+		if before, _, found := strings.Cut(rawURL, "?"); found {
+			return before + "?" + endpointresolver.Redacted
+		}
+		return rawURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), endpointresolver.Redacted)
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			name, _, _ := strings.Cut(param, "=")
+			params[i] = name + "=" + endpointresolver.Redacted
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.String()
+}
+
+// RedactText returns the text provided with every URL it mentions redacted as by RedactURL.
+func (r Redactor) RedactText(text string) string {
+	return urlPattern.ReplaceAllStringFunc(text, func(u string) string {
+		// punctuation following the URL is kept out of it
+		trimmed := strings.TrimRight(u, ".,:;")
+		return r.RedactURL(trimmed) + u[len(trimmed):]
+	})
+}
+
+// RedactError returns an error whose message is the one of the error provided with its URLs redacted, and which wraps
+// it, so that errors.Is and errors.As still match it.
+func (r Redactor) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{error: err, message: r.RedactText(err.Error())}
+}
+
+// redactedError wraps an error whose message is redacted.
+type redactedError struct {
+	error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.error
+}
+
+// RedactConf returns a copy of the resolving config provided whose custom headers, credentials and path queries are
+// redacted, and without its body.
+func (r Redactor) RedactConf(conf endpointresolver.ResolveConf) endpointresolver.ResolveConf {
+	conf.CustomHeaders = r.RedactHeaders(conf.CustomHeaders)
+	conf.Auth = conf.Auth.Redacted()
+	conf.Paths = r.redactURLs(conf.Paths)
+	conf.Body = nil
+	return conf
+}
+
+// RedactURLs returns a copy of the URLs provided whose sensitive response headers, and the queries of the URLs
+// requested along their redirect chains, are redacted.
+func (r Redactor) RedactURLs(urls []endpointresolver.URLResult) []endpointresolver.URLResult {
+	if urls == nil {
+		return nil
+	}
+	redacted := make([]endpointresolver.URLResult, 0, len(urls))
+	for _, u := range urls {
+		u.URL = r.RedactURL(u.URL)
+		u.RequestedURL = r.RedactURL(u.RequestedURL)
+		u.Aliases = r.redactURLs(u.Aliases)
+		if u.RedirectChain != nil {
+			chain := make([]endpointresolver.RedirectHop, 0, len(u.RedirectChain))
+			for _, hop := range u.RedirectChain {
+				hop.URL = r.RedactURL(hop.URL)
+				hop.Location = r.RedactURL(hop.Location)
+				chain = append(chain, hop)
+			}
+			u.RedirectChain = chain
+		}
+		u.Response.Headers = r.RedactHeaders(u.Response.Headers)
+		redacted = append(redacted, u)
+	}
+	return redacted
+}
+
+// RedactResult returns a copy of the result provided whose URLs, and those of its warnings, are redacted.
+func (r Redactor) RedactResult(result endpointresolver.Result) endpointresolver.Result {
+	result.URLs = r.RedactURLs(result.URLs)
+	result.Warnings = r.RedactWarnings(result.Warnings)
+	return result
+}
+
+// RedactWarnings returns a copy of the warnings provided whose URLs, and those their messages mention, are redacted.
+func (r Redactor) RedactWarnings(warnings []endpointresolver.Warning) []endpointresolver.Warning {
+	if warnings == nil {
+		return nil
+	}
+	redacted := make([]endpointresolver.Warning, 0, len(warnings))
+	for _, warning := range warnings {
+		warning.URL = r.RedactURL(warning.URL)
+		warning.Message = r.RedactText(warning.Message)
+		redacted = append(redacted, warning)
+	}
+	return redacted
+}
+
+// redact returns a copy of the parameters or results of a call, as passed to span decorators, whose resolving config,
+// URLs, warnings, result and error are redacted.
+func (r Redactor) redact(values map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		switch v := value.(type) {
+		case endpointresolver.ResolveConf:
+			value = r.RedactConf(v)
+		case []endpointresolver.URLResult:
+			value = r.RedactURLs(v)
+		case []endpointresolver.Warning:
+			value = r.RedactWarnings(v)
+		case endpointresolver.Result:
+			value = r.RedactResult(v)
+		case error:
+			value = r.RedactError(v)
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+func (r Redactor) redactURLs(urls []string) []string {
+	if urls == nil {
+		return nil
+	}
+	redacted := make([]string, 0, len(urls))
+	for _, u := range urls {
+		redacted = append(redacted, r.RedactURL(u))
+	}
+	return redacted
+}
+
+func (r Redactor) headers() []string {
+	if r.Headers == nil {
+		return defaultRedactedHeaders
+	}
+	return r.Headers
+}
+
+func (r Redactor) patterns() []*regexp.Regexp {
+	if r.Patterns == nil {
+		return defaultRedactedPatterns
+	}
+	return r.Patterns
+}
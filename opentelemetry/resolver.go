@@ -1,17 +1,16 @@
 // Code generated by gowrap. DO NOT EDIT.
-// template: https://raw.githubusercontent.com/hexdigest/gowrap/6c8f05695fec23df85903a8da0af66ac414e2a63/templates/opentelemetry
+// template: templates/opentelemetry
 // gowrap: http://github.com/hexdigest/gowrap
 
 package opentelemetry
 
-//go:generate gowrap gen -p github.com/detectify/endpoint-resolver -i Resolver -t https://raw.githubusercontent.com/hexdigest/gowrap/6c8f05695fec23df85903a8da0af66ac414e2a63/templates/opentelemetry -o resolver.go -l ""
+//go:generate gowrap gen -p github.com/detectify/endpoint-resolver -i Resolver -t templates/opentelemetry -o resolver.go -l ""
 
 import (
 	"context"
 
 	endpointresolver "github.com/detectify/endpoint-resolver"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -20,13 +19,16 @@ type ResolverWithTracing struct {
 	endpointresolver.Resolver
 	_instance      string
 	_spanDecorator func(span trace.Span, params, results map[string]interface{})
+	_redactor      Redactor
 }
 
-// NewResolverWithTracing returns ResolverWithTracing
+// NewResolverWithTracing returns ResolverWithTracing, decorating spans with DefaultSpanDecorator unless another span
+// decorator is provided
 func NewResolverWithTracing(base endpointresolver.Resolver, instance string, spanDecorator ...func(span trace.Span, params, results map[string]interface{})) ResolverWithTracing {
 	d := ResolverWithTracing{
-		Resolver:  base,
-		_instance: instance,
+		Resolver:       base,
+		_instance:      instance,
+		_spanDecorator: DefaultSpanDecorator,
 	}
 
 	if len(spanDecorator) > 0 && spanDecorator[0] != nil {
@@ -36,24 +38,22 @@ func NewResolverWithTracing(base endpointresolver.Resolver, instance string, spa
 	return d
 }
 
+// WithRedactor returns a copy of ResolverWithTracing redacting the parameters and results passed to its span
+// decorator with the Redactor provided, instead of the default one
+func (_d ResolverWithTracing) WithRedactor(redactor Redactor) ResolverWithTracing {
+	_d._redactor = redactor
+	return _d
+}
+
 // Resolve implements endpointresolver.Resolver
-func (_d ResolverWithTracing) Resolve(ctx context.Context, conf endpointresolver.ResolveConf) (urls []string, err error) {
+func (_d ResolverWithTracing) Resolve(ctx context.Context, conf endpointresolver.ResolveConf) (result endpointresolver.Result, err error) {
 	ctx, _span := otel.Tracer(_d._instance).Start(ctx, "endpointresolver.Resolver.Resolve")
 	defer func() {
-		if _d._spanDecorator != nil {
-			_d._spanDecorator(_span, map[string]interface{}{
-				"ctx":  ctx,
-				"conf": conf}, map[string]interface{}{
-				"urls": urls,
-				"err":  err})
-		} else if err != nil {
-			_span.RecordError(err)
-			_span.SetAttributes(
-				attribute.String("event", "error"),
-				attribute.String("message", err.Error()),
-			)
-		}
-
+		_d._spanDecorator(_span, _d._redactor.redact(map[string]interface{}{
+			"ctx":  ctx,
+			"conf": conf}), _d._redactor.redact(map[string]interface{}{
+			"result": result,
+			"err":    err}))
 		_span.End()
 	}()
 	return _d.Resolver.Resolve(ctx, conf)
@@ -17,14 +17,30 @@ type ResolveConf struct {
 
 	// Any custom headers that might be needed so that endpoint-resolver's requests come across
 	CustomHeaders map[string]string
+
+	// Auth holds the credentials requests are authenticated with, if any
+	Auth Auth
+
+	// Paths requests are sent to on each open port, in order until one responds successfully. Defaults to "/".
+	Paths []string
+
+	// Method is the HTTP method requests are sent with, among GET, HEAD and OPTIONS. Defaults to GET. Requests sent with
+	// HEAD are sent again with GET when the method is rejected.
+	Method string
+
+	// Body is sent with each request, if any
+	Body []byte
+
+	// ExpectedResponse holds the assertions responses must satisfy for URLs to be considered reachable
+	ExpectedResponse ExpectedResponse
 }
 
 // Resolver provides an interface which facilitates the process to resolve an endpoint.
 type Resolver interface {
 
-	// Resolve consumes the resolving config provided and will return back a list of URLs consisting of hostname with
-	// open ports found, or an error.
-	Resolve(ctx context.Context, conf ResolveConf) (urls []string, err error)
+	// Resolve consumes the resolving config provided and will return back a Result listing the URLs consisting of
+	// hostname with open ports found, or an error.
+	Resolve(ctx context.Context, conf ResolveConf) (result Result, err error)
 }
 
 // Checker provides methods executing the actual endpoint resolution checks performed by the Resolver
@@ -35,13 +51,15 @@ type Checker interface {
 	// NativeDNS initializes the DNS resolution process by using the cluster-internal DNS resolvers.
 	NativeDNS(ctx context.Context, hostname string) (ips []string, err error)
 
-	// Ports consumes a list of IPs discovered as well as ports and returns back a list of open ports accross them.
-	// It does that by looping (max 3 attempts) through the IPs discovered and consequently the ports provided, and executes
-	// a TCP-dial on each combination.
+	// Ports consumes a list of IPs discovered as well as ports and returns back a list of open ports accross them, in
+	// ascending order. It does that by looping through the IPs discovered and consequently the ports provided, and
+	// executes a TCP-dial on each combination, attempting again as many times as the implementation is configured to.
 	Ports(ctx context.Context, ips []string, ports []int) (openPorts []int, err error)
 
-	// HTTP sends an HTTP request to the open ports found on your hostname and returns back a list of URLs. It might be
-	// that no URLs are to be returned - in that case an out-of-scope error might be returned, or a HTTP timeout warning. In
-	// the case the user agent provided resulted in the request being blocked, then a relevant error is returned.
-	HTTP(ctx context.Context, userAgent, hostname string, customHeaders map[string]string, openPorts []int) ([]string, error)
+	// HTTP sends an HTTP request, as configured by the resolving config provided, to the open ports found on your
+	// hostname and returns back a list of URLs, each with the redirect chain that led to it, along with warnings about
+	// URLs reached out of scope or responding slowly. URLs are sorted with HTTPS first, then by hostname and port, and
+	// warnings by URL. In the case the user agent provided resulted in the request being blocked, then a relevant error
+	// is returned.
+	HTTP(ctx context.Context, conf ResolveConf, hostname string, openPorts []int) ([]URLResult, []Warning, error)
 }
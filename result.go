@@ -0,0 +1,290 @@
+package endpointresolver
+
+import "time"
+
+// Result holds the outcome of an endpoint resolution
+type Result struct {
+	// URLs found reachable during the HTTP check, along with how they were reached
+	URLs []URLResult
+
+	// The number of attempts made by each check stage, including retries
+	Attempts map[Stage]int
+
+	// Conditions worth attention found while resolving, which did not prevent the URLs from being found
+	Warnings []Warning
+}
+
+// Warning describes a condition worth attention found while resolving
+type Warning struct {
+	// The kind of condition found
+	Code WarningCode
+
+	// A human-readable description of the condition
+	Message string
+
+	// The URL affected, if any
+	URL string
+}
+
+// URLResult describes a single URL found reachable during the HTTP check
+type URLResult struct {
+	// The URL the response was eventually received from, after any redirects were followed
+	URL string
+
+	// The URL initially requested
+	RequestedURL string
+
+	// Every response received while requesting RequestedURL, in order. The last hop is the response received from URL.
+	RedirectChain []RedirectHop
+
+	// The reason redirects stopped being followed before reaching a non-redirect response, if any
+	RedirectStop RedirectStopReason
+
+	// The total time taken to receive the response from URL, including any redirects
+	Duration time.Duration
+
+	// The breakdown of Duration, summed across every request of the redirect chain
+	Timings Timings
+
+	// The protocol the response from URL was received over
+	Protocol Protocol
+
+	// The protocols URL was found to support. Unless protocol probing is enabled, only Protocol is listed.
+	Protocols []Protocol
+
+	// The alternative services advertised by URL through the Alt-Svc header
+	AltServices []AltService
+
+	// The source IP address the response from URL was requested from. When using a proxy, this is the address the
+	// proxy was connected from.
+	SourceIP string
+
+	// The number of attempts made to request RequestedURL, including retries
+	Attempts int
+
+	// The response received from URL
+	Response ResponseMetadata
+
+	// Other URLs found equivalent to URL when de-duplication is enabled, either requested URLs which led to URL or URLs
+	// of the same host responding identically
+	Aliases []string
+}
+
+// ResponseMetadata describes an HTTP response
+type ResponseMetadata struct {
+	// The HTTP status code returned
+	StatusCode int
+
+	// The response headers selected to be reported, with multiple values joined by commas
+	Headers map[string]string
+
+	// The Content-Type header returned
+	ContentType string
+
+	// The Content-Length header returned, or -1 when unknown
+	ContentLength int64
+
+	// The HTML title of the body, if any
+	Title string
+
+	// The hex-encoded SHA-256 hash of the beginning of the body, up to the read limit configured
+	BodyHash string
+
+	// The number of bytes of the body read, and hashed
+	BodyBytesRead int64
+
+	// The Server header returned
+	Server string
+
+	// The WAF and CDN providers detected from the headers, cookies and body returned, in alphabetical order
+	Providers []string
+
+	// The markers of bot challenge and block pages found in the body, if any
+	ChallengeMarkers []string
+}
+
+// BlockEvidence describes how a request was found blocked due to its user agent, by comparing its response with the
+// response to the same request sent with a reference user agent
+type BlockEvidence struct {
+	// The URL requested
+	URL string
+
+	// The HTTP status code returned to the user agent, or 0 when no response was received
+	StatusCode int
+
+	// The hash of the body returned to the user agent, if read
+	BodyHash string
+
+	// The markers of bot challenge and block pages found in the body returned to the user agent
+	ChallengeMarkers []string
+
+	// The reference user agent the request succeeded with
+	ReferenceUserAgent string
+
+	// The HTTP status code returned to the reference user agent
+	ReferenceStatusCode int
+
+	// The hash of the body returned to the reference user agent, if read
+	ReferenceBodyHash string
+
+	// The WAF and CDN providers detected from either response, in alphabetical order
+	Providers []string
+}
+
+// AltService describes an alternative service advertised through the Alt-Svc header
+type AltService struct {
+	// The ALPN identifier of the protocol advertised, e.g. "h3"
+	Protocol Protocol
+
+	// The authority the protocol is served on, e.g. ":443". An empty host means the same host as the advertising URL.
+	Authority string
+}
+
+// RedirectHop describes a single response received while following redirects
+type RedirectHop struct {
+	// The URL requested for this hop
+	URL string
+
+	// The HTTP status code returned
+	StatusCode int
+
+	// The Location header returned, if any
+	Location string
+
+	// The time taken for this hop to respond
+	Duration time.Duration
+
+	// The breakdown of Duration
+	Timings Timings
+}
+
+// Timings breaks down the time taken by HTTP requests. Phases skipped, e.g. when reusing a connection, take no time.
+type Timings struct {
+	// The time taken to resolve the hostname
+	DNS time.Duration
+
+	// The time taken to establish the TCP connection, including through a proxy
+	Connect time.Duration
+
+	// The time taken by the TLS handshake
+	TLSHandshake time.Duration
+
+	// The time taken from starting the request to receiving the first response byte
+	TimeToFirstByte time.Duration
+
+	// The time taken from starting the request to receiving the response headers
+	Total time.Duration
+}
+
+// Get returns the duration of the timing provided, or zero for unknown timings.
+func (t Timings) Get(timing Timing) time.Duration {
+	switch timing {
+	case TimingDNS:
+		return t.DNS
+	case TimingConnect:
+		return t.Connect
+	case TimingTLSHandshake:
+		return t.TLSHandshake
+	case TimingTimeToFirstByte:
+		return t.TimeToFirstByte
+	case TimingTotal:
+		return t.Total
+	default:
+		return 0
+	}
+}
+
+// Add returns the sum of both timings.
+func (t Timings) Add(other Timings) Timings {
+	return Timings{
+		DNS:             t.DNS + other.DNS,
+		Connect:         t.Connect + other.Connect,
+		TLSHandshake:    t.TLSHandshake + other.TLSHandshake,
+		TimeToFirstByte: t.TimeToFirstByte + other.TimeToFirstByte,
+		Total:           t.Total + other.Total,
+	}
+}
+
+// Timing identifies one of the Timings
+type Timing string
+
+const (
+	// TimingDNS is Timings.DNS
+	TimingDNS Timing = "dns"
+
+	// TimingConnect is Timings.Connect
+	TimingConnect Timing = "connect"
+
+	// TimingTLSHandshake is Timings.TLSHandshake
+	TimingTLSHandshake Timing = "tls_handshake"
+
+	// TimingTimeToFirstByte is Timings.TimeToFirstByte
+	TimingTimeToFirstByte Timing = "ttfb"
+
+	// TimingTotal is Timings.Total
+	TimingTotal Timing = "total"
+)
+
+// Protocol identifies an HTTP protocol version by its ALPN identifier
+type Protocol string
+
+const (
+	// ProtocolHTTP1 is HTTP/1.1
+	ProtocolHTTP1 Protocol = "http/1.1"
+
+	// ProtocolHTTP2 is HTTP/2 over TLS
+	ProtocolHTTP2 Protocol = "h2"
+
+	// ProtocolHTTP3 is HTTP/3 over QUIC
+	ProtocolHTTP3 Protocol = "h3"
+)
+
+// RedirectStopReason describes why following redirects stopped before reaching a non-redirect response
+type RedirectStopReason string
+
+const (
+	// RedirectStopNone means that redirects, if any, were followed to the end
+	RedirectStopNone RedirectStopReason = ""
+
+	// RedirectStopOutOfScope means that a redirect pointed outside the scope of the endpoint
+	RedirectStopOutOfScope RedirectStopReason = "out_of_scope"
+
+	// RedirectStopLimitReached means that the maximum number of redirects was reached
+	RedirectStopLimitReached RedirectStopReason = "limit_reached"
+
+	// RedirectStopLoopDetected means that a redirect pointed to a URL already visited
+	RedirectStopLoopDetected RedirectStopReason = "loop_detected"
+)
+
+// WarningCode identifies the kind of condition a Warning describes
+type WarningCode string
+
+const (
+	// WarningHTTPTimeout means that a response was received after a time threshold, indicating that responses are
+	// slower than anticipated
+	WarningHTTPTimeout WarningCode = "http_timeout"
+
+	// WarningRedirectedOutOfScope means that a URL redirected out of scope, or was reached out of scope
+	WarningRedirectedOutOfScope WarningCode = "redirected_out_of_scope"
+
+	// WarningBlockedByUserAgent means that a URL responded with a block or challenge page to the user agent, but not
+	// to a reference user agent, while other URLs were not blocked
+	WarningBlockedByUserAgent WarningCode = "blocked_by_user_agent"
+)
+
+// Stage identifies a check executed during an endpoint resolution
+type Stage string
+
+const (
+	// StageExternalDNS is the DNS resolution through external DNS resolvers
+	StageExternalDNS Stage = "external_dns"
+
+	// StageNativeDNS is the DNS resolution through the native DNS resolvers
+	StageNativeDNS Stage = "native_dns"
+
+	// StagePorts is the check for open ports
+	StagePorts Stage = "ports"
+
+	// StageHTTP is the check for URLs responding to HTTP requests
+	StageHTTP Stage = "http"
+)
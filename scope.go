@@ -0,0 +1,149 @@
+package endpointresolver
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/detectify/n5/domain"
+)
+
+// ScopePolicy decides which URLs are considered within the scope of the endpoint being resolved. It is used both to
+// decide whether a redirect should be followed and whether the URLs found are in scope.
+type ScopePolicy interface {
+	// InScope reports whether the URL provided is in scope of the hostname of the endpoint being resolved.
+	InScope(hostname string, u *url.URL) bool
+}
+
+// ExactHostScope considers only URLs on the exact hostname of the endpoint to be in scope.
+type ExactHostScope struct{}
+
+// InScope implements ScopePolicy
+func (ExactHostScope) InScope(hostname string, u *url.URL) bool {
+	return strings.EqualFold(u.Hostname(), hostname)
+}
+
+// RegistrableDomainScope considers URLs on any hostname sharing the registrable domain (apex) of the endpoint to be in
+// scope, e.g. www.example.com and api.example.com for the endpoint example.com.
+type RegistrableDomainScope struct{}
+
+// InScope implements ScopePolicy
+func (RegistrableDomainScope) InScope(hostname string, u *url.URL) bool {
+	if strings.EqualFold(u.Hostname(), hostname) {
+		return true
+	}
+	apex := domain.Apex(hostname)
+	return len(apex) > 0 && apex == domain.Apex(u.Hostname())
+}
+
+// SubdomainScope considers URLs on a domain and any of its subdomains to be in scope. When Domain is empty, the
+// hostname of the endpoint is used.
+type SubdomainScope struct {
+	// The domain whose subdomains are in scope
+	Domain string
+}
+
+// InScope implements ScopePolicy
+func (s SubdomainScope) InScope(hostname string, u *url.URL) bool {
+	parent := s.Domain
+	if len(parent) == 0 {
+		parent = hostname
+	}
+	return strings.EqualFold(u.Hostname(), parent) || domain.Contains(parent, u.Hostname())
+}
+
+// AllowlistScope considers only URLs on an explicit list of hostnames to be in scope. Entries prefixed with "*." also
+// match any subdomain of the rest of the entry.
+type AllowlistScope struct {
+	// The hostnames which are in scope
+	Hosts []string
+}
+
+// InScope implements ScopePolicy
+func (s AllowlistScope) InScope(_ string, u *url.URL) bool {
+	for _, host := range s.Hosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+		if strings.HasPrefix(host, "*.") && domain.IsSubdomainOf(u.Hostname(), strings.TrimPrefix(host, "*.")) {
+			return true
+		}
+	}
+	return false
+}
+
+// SchemeScope considers only URLs using one of the listed schemes to be in scope, regardless of their hostname.
+type SchemeScope struct {
+	// The schemes which are in scope, e.g. "https"
+	Schemes []string
+}
+
+// InScope implements ScopePolicy
+func (s SchemeScope) InScope(_ string, u *url.URL) bool {
+	for _, scheme := range s.Schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// PortScope considers only URLs on one of the listed ports to be in scope, regardless of their hostname. URLs without
+// an explicit port are considered to be on the default port of their scheme.
+type PortScope struct {
+	// The ports which are in scope
+	Ports []int
+}
+
+// InScope implements ScopePolicy
+func (s PortScope) InScope(_ string, u *url.URL) bool {
+	port := URLPort(u)
+	for _, p := range s.Ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// AllScopes considers a URL in scope only if all of its policies do, e.g. to restrict a hostname policy to HTTPS.
+type AllScopes []ScopePolicy
+
+// InScope implements ScopePolicy
+func (s AllScopes) InScope(hostname string, u *url.URL) bool {
+	for _, policy := range s {
+		if !policy.InScope(hostname, u) {
+			return false
+		}
+	}
+	return true
+}
+
+// AnyScopes considers a URL in scope if any of its policies does, e.g. to extend a hostname policy with an allowlist.
+type AnyScopes []ScopePolicy
+
+// InScope implements ScopePolicy
+func (s AnyScopes) InScope(hostname string, u *url.URL) bool {
+	for _, policy := range s {
+		if policy.InScope(hostname, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// URLPort returns the port of the URL provided, falling back to the default port of its scheme. Returns 0 when the
+// port can not be determined.
+func URLPort(u *url.URL) int {
+	if port := u.Port(); len(port) > 0 {
+		portInt, _ := strconv.Atoi(port)
+		return portInt
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package endpointresolver
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   ScopePolicy
+		url      string
+		expected bool
+	}{
+		{"exact host matches", ExactHostScope{}, "https://example.com/", true},
+		{"exact host rejects subdomain", ExactHostScope{}, "https://www.example.com/", false},
+		{"registrable domain matches sibling", RegistrableDomainScope{}, "https://api.example.com/", true},
+		{"registrable domain rejects other domain", RegistrableDomainScope{}, "https://example.org/", false},
+		{"subdomain matches subdomain", SubdomainScope{}, "https://www.example.com/", true},
+		{"subdomain rejects parent", SubdomainScope{Domain: "www.example.com"}, "https://example.com/", false},
+		{"allowlist matches host", AllowlistScope{Hosts: []string{"cdn.example.org"}}, "https://cdn.example.org/", true},
+		{"allowlist matches wildcard", AllowlistScope{Hosts: []string{"*.example.org"}}, "https://a.example.org/", true},
+		{"allowlist rejects other host", AllowlistScope{Hosts: []string{"*.example.org"}}, "https://example.com/", false},
+		{"scheme matches", SchemeScope{Schemes: []string{"https"}}, "https://example.com/", true},
+		{"scheme rejects", SchemeScope{Schemes: []string{"https"}}, "http://example.com/", false},
+		{"port matches default port", PortScope{Ports: []int{443}}, "https://example.com/", true},
+		{"port rejects", PortScope{Ports: []int{443}}, "https://example.com:8443/", false},
+		{"all requires every policy", AllScopes{SubdomainScope{}, SchemeScope{Schemes: []string{"https"}}}, "http://example.com/", false},
+		{"any requires one policy", AnyScopes{ExactHostScope{}, AllowlistScope{Hosts: []string{"example.org"}}}, "http://example.org/", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, test.policy.InScope("example.com", u))
+		})
+	}
+}